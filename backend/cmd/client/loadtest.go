@@ -0,0 +1,370 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"party-game/pkg/handlers"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+)
+
+// choiceIdPattern picks the answer ids out of the round-choices page.
+var choiceIdPattern = regexp.MustCompile(`data-id="([^"]+)"`)
+
+type loadTestConfig struct {
+	addr    string
+	games   int
+	players int
+	rounds  int
+	ramp    time.Duration
+	timeout time.Duration
+}
+
+// runLoadTest plays the configured parties at once. Every simulated player
+// comes from the same IP, so bigger runs need the server's [limits] raised or
+// turned off, e.g. with PARTYGAME_LIMITS_CREATE_PLAYER_PER_MINUTE=0.
+func runLoadTest(args []string) error {
+	config := loadTestConfig{}
+	flags := flag.NewFlagSet("loadtest", flag.ExitOnError)
+	flags.StringVar(&config.addr, "addr", "http://localhost:8888", "base URL of the server")
+	flags.IntVar(&config.games, "games", 10, "number of concurrent games")
+	flags.IntVar(&config.players, "players", 4, "players per game")
+	flags.IntVar(&config.rounds, "rounds", 3, "rounds played per game")
+	flags.DurationVar(&config.ramp, "ramp", 100*time.Millisecond, "delay between starting two games")
+	flags.DurationVar(&config.timeout, "timeout", 90*time.Second, "timeout of a single HTTP request")
+	flags.Parse(args)
+
+	if config.games < 1 || config.players < 2 || config.rounds < 1 {
+		return errors.New("need at least 1 game, 2 players per game and 1 round")
+	}
+
+	stats := newLoadTestStats()
+	stop := make(chan struct{})
+	go stats.sampleGoroutines(config, stop)
+
+	fmt.Printf("Starting %d games with %d players each against %s\n", config.games, config.players, config.addr)
+	start := time.Now()
+	var wg sync.WaitGroup
+	for g := 0; g < config.games; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			if err := playParty(config, stats, g); err != nil {
+				stats.failParty(err)
+			}
+		}(g)
+		time.Sleep(config.ramp)
+	}
+	wg.Wait()
+	close(stop)
+
+	stats.report(os.Stdout, time.Since(start), config)
+	return nil
+}
+
+type simPlayer struct {
+	name   string
+	client *http.Client
+	addr   string
+	stats  *loadTestStats
+}
+
+func newSimPlayer(config loadTestConfig, stats *loadTestStats, name string) (*simPlayer, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return nil, err
+	}
+	return &simPlayer{
+		name:   name,
+		client: &http.Client{Jar: jar, Timeout: config.timeout},
+		addr:   strings.TrimSuffix(config.addr, "/"),
+		stats:  stats,
+	}, nil
+}
+
+// do sends a request, records its latency under the path and returns the body.
+// A nil form sends a GET, anything else a POST with the CSRF token the pages
+// set in the cookie.
+func (p *simPlayer) do(path string, form url.Values) (string, error) {
+	start := time.Now()
+	var req *http.Request
+	var err error
+	if form == nil {
+		req, err = http.NewRequest(http.MethodGet, p.addr+path, nil)
+	} else if req, err = http.NewRequest(http.MethodPost, p.addr+path, strings.NewReader(form.Encode())); err == nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set(handlers.CSRFHeader, p.csrfToken())
+	}
+	if err != nil {
+		return "", err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		p.stats.record(path, time.Since(start), err)
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err == nil && resp.StatusCode >= 400 {
+		err = errors.New(path + " returned " + resp.Status + ": " + strings.TrimSpace(string(body)))
+	}
+	p.stats.record(path, time.Since(start), err)
+	return string(body), err
+}
+
+// csrfToken returns the token of the cookie the server set on the first page.
+func (p *simPlayer) csrfToken() string {
+	u, err := url.Parse(p.addr)
+	if err != nil {
+		return ""
+	}
+	for _, cookie := range p.client.Jar.Cookies(u) {
+		if cookie.Name == handlers.CSRFCookie {
+			return cookie.Value
+		}
+	}
+	return ""
+}
+
+// playParty creates a game with one host and lets the other players join,
+// then plays the configured number of rounds.
+func playParty(config loadTestConfig, stats *loadTestStats, index int) error {
+	password := "loadtest-" + strconv.Itoa(index) + "-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	players := []*simPlayer{}
+	for i := 0; i < config.players; i++ {
+		p, err := newSimPlayer(config, stats, "g"+strconv.Itoa(index)+"p"+strconv.Itoa(i))
+		if err != nil {
+			return err
+		}
+		// The home page hands out the CSRF cookie
+		if _, err := p.do("/", nil); err != nil {
+			return err
+		}
+		if _, err := p.do("/create-player", url.Values{"player-name": {p.name}}); err != nil {
+			return err
+		}
+		players = append(players, p)
+	}
+
+	if _, err := players[0].do("/create-game", url.Values{"game-password": {password}}); err != nil {
+		return err
+	}
+	for _, p := range players[1:] {
+		if _, err := p.do("/join-game", url.Values{"game-password": {password}}); err != nil {
+			return err
+		}
+	}
+
+	for round := 0; round < config.rounds; round++ {
+		err := allPlayers(players, func(p *simPlayer) error {
+			if _, err := p.do("/round-question", nil); err != nil {
+				return err
+			}
+			_, err := p.do("/submit-answer", url.Values{"player-answer": {p.name + " says " + strconv.Itoa(round)}})
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		err = allPlayers(players, func(p *simPlayer) error {
+			page, err := p.do("/round-choice", nil)
+			if err != nil {
+				return err
+			}
+			choices := choiceIdPattern.FindAllStringSubmatch(page, -1)
+			if len(choices) == 0 {
+				return errors.New("no choices offered to " + p.name)
+			}
+			choice := choices[rand.Intn(len(choices))][1]
+			_, err = p.do("/submit-choice", url.Values{"player-choice-id": {choice}})
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		err = allPlayers(players, func(p *simPlayer) error {
+			if _, err := p.do("/round-results", nil); err != nil {
+				return err
+			}
+			_, err := p.do("/new-round-ready", url.Values{})
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	stats.finishParty()
+	return nil
+}
+
+// allPlayers runs step for every player at the same time, the server only
+// answers the long polls once everybody has acted.
+func allPlayers(players []*simPlayer, step func(p *simPlayer) error) error {
+	errs := make(chan error, len(players))
+	for _, p := range players {
+		go func(p *simPlayer) {
+			errs <- step(p)
+		}(p)
+	}
+
+	var firstErr error
+	for range players {
+		if err := <-errs; err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+type loadTestStats struct {
+	lock           sync.Mutex
+	latencies      map[string][]time.Duration
+	errors         map[string]int
+	finishedGames  int
+	failedGames    int
+	lastErrors     []string
+	peakGoroutines int
+}
+
+func newLoadTestStats() *loadTestStats {
+	return &loadTestStats{
+		latencies: make(map[string][]time.Duration),
+		errors:    make(map[string]int),
+	}
+}
+
+func (s *loadTestStats) record(path string, latency time.Duration, err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.latencies[path] = append(s.latencies[path], latency)
+	if err != nil {
+		s.errors[path]++
+	}
+}
+
+func (s *loadTestStats) finishParty() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.finishedGames++
+}
+
+func (s *loadTestStats) failParty(err error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.failedGames++
+	if len(s.lastErrors) < 10 {
+		s.lastErrors = append(s.lastErrors, err.Error())
+	}
+}
+
+// sampleGoroutines keeps the peak number of goroutines of the server, which
+// grows with the requests that wait for the other players.
+func (s *loadTestStats) sampleGoroutines(config loadTestConfig, stop chan struct{}) {
+	ticker := time.NewTicker(250 * time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			n, err := serverGoroutines(config)
+			if err != nil {
+				continue
+			}
+			s.lock.Lock()
+			if n > s.peakGoroutines {
+				s.peakGoroutines = n
+			}
+			s.lock.Unlock()
+		}
+	}
+}
+
+// serverGoroutines reads go_goroutines from the /metrics of the server.
+func serverGoroutines(config loadTestConfig) (int, error) {
+	client := http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(strings.TrimSuffix(config.addr, "/") + "/metrics")
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("GET /metrics: %s", resp.Status)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+	for _, line := range strings.Split(string(body), "\n") {
+		if value, ok := strings.CutPrefix(line, "go_goroutines "); ok {
+			n, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			return int(n), err
+		}
+	}
+	return 0, errors.New("no go_goroutines in /metrics")
+}
+
+func (s *loadTestStats) report(out io.Writer, elapsed time.Duration, config loadTestConfig) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	fmt.Fprintf(out, "\nFinished in %s: %d games completed, %d failed\n", elapsed.Round(time.Millisecond), s.finishedGames, s.failedGames)
+	if now, err := serverGoroutines(config); err != nil {
+		fmt.Fprintf(out, "Server goroutines: unknown, %v\n\n", err)
+	} else {
+		fmt.Fprintf(out, "Server goroutines: peak %d, now %d\n\n", s.peakGoroutines, now)
+	}
+
+	paths := []string{}
+	for path := range s.latencies {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	table := tabwriter.NewWriter(out, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(table, "endpoint\trequests\terrors\tp50\tp90\tp99\tmax\t")
+	for _, path := range paths {
+		latencies := s.latencies[path]
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		fmt.Fprintf(table, "%s\t%d\t%d\t%s\t%s\t%s\t%s\t\n", path, len(latencies), s.errors[path],
+			percentile(latencies, 0.50), percentile(latencies, 0.90), percentile(latencies, 0.99), latencies[len(latencies)-1].Round(time.Millisecond))
+	}
+	table.Flush()
+
+	if len(s.lastErrors) > 0 {
+		fmt.Fprintln(out, "\nFirst errors:")
+		for _, e := range s.lastErrors {
+			fmt.Fprintln(out, "  "+e)
+		}
+	}
+}
+
+// percentile expects sorted latencies.
+func percentile(latencies []time.Duration, p float64) time.Duration {
+	if len(latencies) == 0 {
+		return 0
+	}
+	index := int(float64(len(latencies))*p+0.5) - 1
+	if index < 0 {
+		index = 0
+	}
+	if index >= len(latencies) {
+		index = len(latencies) - 1
+	}
+	return latencies[index].Round(time.Millisecond)
+}
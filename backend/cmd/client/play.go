@@ -0,0 +1,354 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"party-game/pkg/gamelogic"
+	"party-game/pkg/handlers"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+)
+
+type apiClient struct {
+	addr     string
+	client   *http.Client
+	playerId string
+}
+
+// do sends body as JSON and decodes the response into out when it is not nil.
+func (c *apiClient) do(method string, path string, body any, out any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequest(method, c.addr+"/api/v1"+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.playerId != "" {
+		req.Header.Set("X-Player-Id", c.playerId)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		apiErr := handlers.APIError{}
+		if json.NewDecoder(resp.Body).Decode(&apiErr) != nil || apiErr.Message == "" {
+			return errors.New(resp.Status)
+		}
+		return errors.New(apiErr.Message)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// streamEvents sends the type of every game event to events and reconnects
+// when the stream breaks.
+func (c *apiClient) streamEvents(gameId string, events chan<- string) {
+	for {
+		req, err := http.NewRequest(http.MethodGet, c.addr+"/api/v1/games/"+gameId+"/events", nil)
+		if err != nil {
+			return
+		}
+		req.Header.Set("X-Player-Id", c.playerId)
+		resp, err := http.DefaultClient.Do(req)
+		if err == nil {
+			scanner := bufio.NewScanner(resp.Body)
+			for scanner.Scan() {
+				if eventType, ok := strings.CutPrefix(scanner.Text(), "event: "); ok {
+					events <- eventType
+				}
+			}
+			resp.Body.Close()
+		}
+		// Refresh after reconnecting in case events were missed
+		time.Sleep(time.Second)
+		events <- "reconnected"
+	}
+}
+
+type key struct {
+	r    rune
+	name string // set for special keys: up, down, enter, backspace, quit
+}
+
+func readKeys(keys chan<- key) {
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		r, _, err := reader.ReadRune()
+		if err != nil {
+			keys <- key{name: "quit"}
+			return
+		}
+		switch r {
+		case 3, 4: // ctrl-c, ctrl-d
+			keys <- key{name: "quit"}
+		case '\r', '\n':
+			keys <- key{name: "enter"}
+		case 127, 8:
+			keys <- key{name: "backspace"}
+		case 27: // escape sequences for the arrow keys
+			if next, _, _ := reader.ReadRune(); next != '[' {
+				continue
+			}
+			switch arrow, _, _ := reader.ReadRune(); arrow {
+			case 'A':
+				keys <- key{name: "up"}
+			case 'B':
+				keys <- key{name: "down"}
+			}
+		default:
+			if r >= 32 {
+				keys <- key{r: r}
+			}
+		}
+	}
+}
+
+type playState struct {
+	game       handlers.APIGame
+	round      handlers.APIRound
+	scores     []handlers.APIScore
+	awards     []handlers.APIAward // fetched once the game is over
+	input      []rune
+	selected   int
+	readyRound string // the round the player already pressed ready for
+	message    string
+}
+
+func runPlay(args []string) error {
+	flags := flag.NewFlagSet("play", flag.ExitOnError)
+	addr := flags.String("addr", "http://localhost:8888", "base URL of the server")
+	name := flags.String("name", "", "your player name")
+	password := flags.String("password", "", "password of the game to create or join")
+	create := flags.Bool("create", false, "create the game instead of joining it")
+	mode := flags.String("mode", gamelogic.DefaultGameMode, "game mode when creating a game")
+	flags.Parse(args)
+
+	if *name == "" || *password == "" {
+		return errors.New("-name and -password are required")
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return errors.New("play needs an interactive terminal")
+	}
+
+	c := &apiClient{addr: strings.TrimSuffix(*addr, "/"), client: &http.Client{Timeout: 10 * time.Second}}
+	player := handlers.APIPlayer{}
+	if err := c.do(http.MethodPost, "/players", handlers.CreatePlayerRequest{Name: *name}, &player); err != nil {
+		return err
+	}
+	c.playerId = player.Id
+
+	state := &playState{}
+	if *create {
+		err := c.do(http.MethodPost, "/games", handlers.CreateGameRequest{Password: *password, Mode: *mode}, &state.game)
+		if err != nil {
+			return err
+		}
+	} else if err := c.do(http.MethodPost, "/games/join", handlers.JoinGameRequest{Password: *password}, &state.game); err != nil {
+		return err
+	}
+
+	oldState, err := term.MakeRaw(int(os.Stdin.Fd()))
+	if err != nil {
+		return err
+	}
+	defer term.Restore(int(os.Stdin.Fd()), oldState)
+	defer fmt.Print("\x1b[H\x1b[2J")
+
+	events := make(chan string, 16)
+	keys := make(chan key)
+	go c.streamEvents(state.game.Id, events)
+	go readKeys(keys)
+
+	refresh(c, state)
+	for {
+		render(player, state)
+		select {
+		case <-events:
+			refresh(c, state)
+		case k := <-keys:
+			if k.name == "quit" {
+				return nil
+			}
+			handleKey(c, state, k)
+		}
+	}
+}
+
+func refresh(c *apiClient, state *playState) {
+	gameId := state.game.Id
+	previousRound := state.round.Id
+	if err := c.do(http.MethodGet, "/games/"+gameId+"/rounds/current", nil, &state.round); err != nil {
+		state.message = err.Error()
+		return
+	}
+	if state.round.Id != previousRound {
+		state.input = nil
+		state.selected = 0
+		state.message = ""
+	}
+	if state.selected >= len(state.round.Choices) {
+		state.selected = 0
+	}
+	if err := c.do(http.MethodGet, "/games/"+gameId+"/scores", nil, &state.scores); err != nil {
+		state.message = err.Error()
+	}
+	if state.round.Phase == gamelogic.PhaseFinished && state.awards == nil {
+		archived := handlers.APIArchivedGame{}
+		if err := c.do(http.MethodGet, "/games/"+gameId+"/history", nil, &archived); err != nil {
+			state.message = err.Error()
+			return
+		}
+		state.awards = archived.Awards
+	}
+}
+
+func handleKey(c *apiClient, state *playState, k key) {
+	round := state.round
+	roundPath := "/games/" + state.game.Id + "/rounds/" + round.Id
+	switch {
+	case round.Spectating:
+		return
+	case round.Phase == gamelogic.PhaseAnswering && !round.Answered:
+		switch k.name {
+		case "":
+			state.input = append(state.input, k.r)
+		case "backspace":
+			if len(state.input) > 0 {
+				state.input = state.input[:len(state.input)-1]
+			}
+		case "enter":
+			err := c.do(http.MethodPost, roundPath+"/answers", handlers.SubmitAnswerRequest{Text: string(state.input)}, nil)
+			if err != nil {
+				state.message = err.Error()
+				return
+			}
+			state.message = ""
+			refresh(c, state)
+		}
+
+	case round.Phase == gamelogic.PhaseVoting && !round.Voted && len(round.Choices) > 0:
+		switch k.name {
+		case "up":
+			state.selected = (state.selected + len(round.Choices) - 1) % len(round.Choices)
+		case "down":
+			state.selected = (state.selected + 1) % len(round.Choices)
+		case "enter":
+			choice := round.Choices[state.selected]
+			if err := c.do(http.MethodPost, roundPath+"/votes", handlers.SubmitVoteRequest{AnswerId: choice.Id}, nil); err != nil {
+				state.message = err.Error()
+				return
+			}
+			state.message = ""
+			refresh(c, state)
+		}
+
+	case round.Phase == gamelogic.PhaseResults && state.readyRound != round.Id:
+		switch {
+		case k.name == "enter":
+			if err := c.do(http.MethodPost, "/games/"+state.game.Id+"/ready", nil, nil); err != nil {
+				state.message = err.Error()
+				return
+			}
+			state.readyRound = round.Id
+		case k.name == "" && (k.r == 'e' || k.r == 'E'):
+			if err := c.do(http.MethodPost, "/games/"+state.game.Id+"/end", nil, nil); err != nil {
+				state.message = err.Error()
+				return
+			}
+			state.message = ""
+			refresh(c, state)
+		}
+	}
+}
+
+func render(player handlers.APIPlayer, state *playState) {
+	lines := []string{
+		"Party Game - playing as " + player.Name + " (" + state.game.Mode + ")",
+		"",
+		"  " + state.round.Question,
+		"",
+	}
+
+	round := state.round
+	switch {
+	case round.Spectating && round.Phase != gamelogic.PhaseResults && round.Phase != gamelogic.PhaseFinished:
+		lines = append(lines, "The round started before you joined, you play from the next one.")
+	case round.Phase == gamelogic.PhaseAnswering && !round.Answered:
+		lines = append(lines, "Your answer: "+string(state.input)+"_", "", "Press Enter to submit.")
+	case round.Phase == gamelogic.PhaseAnswering:
+		lines = append(lines, "Waiting for the other players to answer...")
+	case round.Phase == gamelogic.PhaseVoting && !round.Voted:
+		lines = append(lines, "Pick the best answer with the arrow keys and press Enter:", "")
+		for i, choice := range round.Choices {
+			cursor := "   "
+			if i == state.selected {
+				cursor = " > "
+			}
+			lines = append(lines, cursor+choice.Text)
+		}
+	case round.Phase == gamelogic.PhaseVoting:
+		lines = append(lines, "Waiting for the other players to vote...")
+	default:
+		lines = append(lines, leaderboardLines(state.scores)...)
+		lines = append(lines, "")
+		switch {
+		case round.Phase == gamelogic.PhaseFinished:
+			for _, a := range state.awards {
+				lines = append(lines, fmt.Sprintf("%s: %s - %s", a.Title, a.PlayerName, a.Detail))
+			}
+			if len(state.awards) > 0 {
+				lines = append(lines, "")
+			}
+			lines = append(lines, "Game over! Press Ctrl-C to leave.")
+		case state.readyRound == round.Id:
+			lines = append(lines, "Waiting for the other players to get ready...")
+		default:
+			lines = append(lines, "Press Enter when you are ready for the next round, E to end the game.")
+		}
+	}
+
+	if state.message != "" {
+		lines = append(lines, "", "! "+state.message)
+	}
+	lines = append(lines, "", "Ctrl-C to quit")
+
+	// In raw mode a newline does not return the cursor to the start of the line
+	fmt.Print("\x1b[H\x1b[2J" + strings.Join(lines, "\r\n"))
+}
+
+func leaderboardLines(scores []handlers.APIScore) []string {
+	lines := []string{"  #  Player               Points  Round"}
+	for _, e := range scores {
+		change := ""
+		if e.RankChange > 0 {
+			change = fmt.Sprintf("  up %d", e.RankChange)
+		} else if e.RankChange < 0 {
+			change = fmt.Sprintf("  down %d", -e.RankChange)
+		}
+		lines = append(lines, fmt.Sprintf("%3d  %-20s %6d  %+5d%s", e.Rank, e.PlayerName, e.Points, e.Delta, change))
+	}
+	return lines
+}
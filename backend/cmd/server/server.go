@@ -1,128 +1,54 @@
 package main
 
 import (
+	"context"
 	"flag"
-	"html/template"
-	"log"
-	"net/http"
-
-	"github.com/gorilla/websocket"
+	"log/slog"
+	"os"
+	"os/signal"
+	"party-game/pkg/config"
+	"party-game/pkg/logging"
+	"party-game/pkg/middleware"
+	"party-game/pkg/server"
+	"syscall"
 )
 
-var addr = flag.String("addr", "localhost:8080", "http service address")
-
-var upgrader = websocket.Upgrader{} // use default options
+func main() {
+	configPath := flag.String("config", os.Getenv("PARTYGAME_CONFIG"), "TOML config file, settings can be overridden with PARTYGAME_<SECTION>_<KEY> environment variables")
+	host := flag.Bool("host", false, "host a party on the local network, same as setting lan.enabled")
+	flag.Parse()
 
-func echo(w http.ResponseWriter, r *http.Request) {
-	c, err := upgrader.Upgrade(w, r, nil)
+	conf, err := config.Load(*configPath)
+	if err == nil && *host {
+		conf.LAN.Enabled = true
+		err = conf.Validate()
+	}
 	if err != nil {
-		log.Print("upgrade:", err)
-		return
+		slog.Error("Invalid configuration", "error", err)
+		os.Exit(1)
 	}
-	defer c.Close()
-	for {
-		mt, message, err := c.ReadMessage()
-		if err != nil {
-			log.Println("read:", err)
-			break
-		}
-		log.Printf("recv: %s", message)
-		err = c.WriteMessage(mt, message)
-		if err != nil {
-			log.Println("write:", err)
-			break
-		}
+
+	handler, sinks, err := logging.New(conf.Logging.LoggingConfig())
+	if err != nil {
+		slog.Error("Could not set up logging", "error", err)
+		os.Exit(1)
 	}
-}
+	defer sinks.Close()
+	slog.SetDefault(slog.New(middleware.NewContextHandler(handler)))
 
-func home(w http.ResponseWriter, r *http.Request) {
-	homeTemplate.Execute(w, "ws://"+r.Host+"/echo")
-}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-func main() {
-	flag.Parse()
-	log.SetFlags(0)
-	http.HandleFunc("/echo", echo)
-	http.HandleFunc("/", home)
-	log.Fatal(http.ListenAndServe(*addr, nil))
+	srv, err := server.New(conf.ServerConfig())
+	if err != nil {
+		slog.Error("Could not create server", "error", err)
+		sinks.Close()
+		os.Exit(1)
+	}
+	srv.Use(middleware.Logging(conf.Logging.RequestLogConfig()))
+	if err := srv.Run(ctx); err != nil {
+		slog.Error("Server stopped", "error", err)
+		sinks.Close()
+		os.Exit(1)
+	}
 }
-
-var homeTemplate = template.Must(template.New("").Parse(`
-<!DOCTYPE html>
-<html>
-<head>
-<meta charset="utf-8">
-<script>
-window.addEventListener("load", function(evt) {
-
-    var output = document.getElementById("output");
-    var input = document.getElementById("input");
-    var ws;
-
-    var print = function(message) {
-        var d = document.createElement("div");
-        d.textContent = message;
-        output.appendChild(d);
-        output.scroll(0, output.scrollHeight);
-    };
-
-    document.getElementById("open").onclick = function(evt) {
-        if (ws) {
-            return false;
-        }
-        ws = new WebSocket("{{.}}");
-        ws.onopen = function(evt) {
-            print("OPEN");
-        }
-        ws.onclose = function(evt) {
-            print("CLOSE");
-            ws = null;
-        }
-        ws.onmessage = function(evt) {
-            print("RESPONSE: " + evt.data);
-        }
-        ws.onerror = function(evt) {
-            print("ERROR: " + evt.data);
-        }
-        return false;
-    };
-
-    document.getElementById("send").onclick = function(evt) {
-        if (!ws) {
-            return false;
-        }
-        print("SEND: " + input.value);
-        ws.send(input.value);
-        return false;
-    };
-
-    document.getElementById("close").onclick = function(evt) {
-        if (!ws) {
-            return false;
-        }
-        ws.close();
-        return false;
-    };
-
-});
-</script>
-</head>
-<body>
-<table>
-<tr><td valign="top" width="50%">
-<p>Click "Open" to create a connection to the server,
-"Send" to send a message to the server and "Close" to close the connection.
-You can change the message and send multiple times.
-<p>
-<form>
-<button id="open">Open</button>
-<button id="close">Close</button>
-<p><input id="input" type="text" value="Hello world!">
-<button id="send">Send</button>
-</form>
-</td><td valign="top" width="50%">
-<div id="output" style="max-height: 70vh;overflow-y: scroll;"></div>
-</td></tr></table>
-</body>
-</html>
-`))
@@ -0,0 +1,451 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"party-game/pkg/gamelogic"
+	"party-game/pkg/handlers"
+	"party-game/pkg/lan"
+	"party-game/pkg/logging"
+	"party-game/pkg/middleware"
+	"party-game/pkg/ratelimit"
+	"party-game/pkg/server"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Every setting can be overridden with an environment variable named after
+// its section and key, e.g. PARTYGAME_SERVER_ADDR or PARTYGAME_LOGGING_LEVEL.
+// Lists are comma separated, the log sinks can only be set in the file.
+const envPrefix string = "PARTYGAME"
+
+type Config struct {
+	Server  Server  `toml:"server"`
+	Logging Logging `toml:"logging"`
+	Game    Game    `toml:"game"`
+	LAN     LAN     `toml:"lan"`
+	Admin   Admin   `toml:"admin"`
+	Limits  Limits  `toml:"limits"`
+}
+
+type Server struct {
+	Addr              string        `toml:"addr"`
+	TLSCertFile       string        `toml:"tls_cert_file"`
+	TLSKeyFile        string        `toml:"tls_key_file"`
+	TemplatesDir      string        `toml:"templates_dir"`
+	StateFile         string        `toml:"state_file"`
+	ReadHeaderTimeout time.Duration `toml:"read_header_timeout"`
+	ReadTimeout       time.Duration `toml:"read_timeout"`
+	WriteTimeout      time.Duration `toml:"write_timeout"`
+	IdleTimeout       time.Duration `toml:"idle_timeout"`
+	ShutdownTimeout   time.Duration `toml:"shutdown_timeout"`
+	LongPollTimeout   time.Duration `toml:"long_poll_timeout"`
+}
+
+type Logging struct {
+	Level string `toml:"level"`
+	Sinks []Sink `toml:"sinks"`
+	// Request fields that are never logged and whether form bodies are logged
+	Redact  []string `toml:"redact"`
+	LogForm bool     `toml:"log_form"`
+}
+
+type Sink struct {
+	Type       string `toml:"type"`
+	Level      string `toml:"level"`
+	Format     string `toml:"format"`
+	Path       string `toml:"path"`
+	MaxSizeMB  int    `toml:"max_size_mb"`
+	MaxBackups int    `toml:"max_backups"`
+	Addr       string `toml:"addr"`
+	Protocol   string `toml:"protocol"`
+}
+
+type Game struct {
+	AnswerMinLength int  `toml:"answer_min_length"`
+	AnswerMaxLength int  `toml:"answer_max_length"`
+	FilterProfanity bool `toml:"filter_profanity"`
+	// The janitor ends idle games and deletes finished games and the players
+	// left without one, every janitor_interval
+	JanitorInterval time.Duration `toml:"janitor_interval"`
+	IdleGameTTL     time.Duration `toml:"idle_game_ttl"`
+	FinishedGameTTL time.Duration `toml:"finished_game_ttl"`
+	PlayerTTL       time.Duration `toml:"player_ttl"`
+	HistoryTTL      time.Duration `toml:"history_ttl"`
+	MinPlayers      int           `toml:"min_players"`
+	MaxPlayers      int           `toml:"max_players_per_game"`
+	// What happens to players joining after the first round's voting started:
+	// reject, spectate until the next round or immediate
+	LateJoin string `toml:"late_join"`
+	// Players not seen for away_after are away and the rounds stop waiting
+	// for them until they are back, 0 disables it
+	AwayAfter time.Duration `toml:"away_after"`
+	// Keys the index of the game passwords, never saved with the state
+	RoomSecret string `toml:"room_secret"`
+}
+
+type Admin struct {
+	Token string `toml:"token"`
+	Pprof bool   `toml:"pprof"`
+}
+
+// Limits are per client IP, or per player for the submissions and the wrong
+// password lockout. A per_minute of 0 turns that limit off.
+type Limits struct {
+	CreatePlayerPerMinute int           `toml:"create_player_per_minute"`
+	CreatePlayerBurst     int           `toml:"create_player_burst"`
+	JoinPerMinute         int           `toml:"join_per_minute"`
+	JoinBurst             int           `toml:"join_burst"`
+	SubmitPerMinute       int           `toml:"submit_per_minute"`
+	SubmitBurst           int           `toml:"submit_burst"`
+	BadPasswordAttempts   int           `toml:"bad_password_attempts"`
+	BadPasswordLockout    time.Duration `toml:"bad_password_lockout"`
+	MaxGamesPerIP         int           `toml:"max_games_per_ip"`
+}
+
+type LAN struct {
+	Enabled   bool   `toml:"enabled"`
+	Interface string `toml:"interface"`
+	Hostname  string `toml:"hostname"`
+	Instance  string `toml:"instance"`
+}
+
+func Default() Config {
+	serverConfig := server.DefaultConfig()
+	gameConfig := gamelogic.DefaultConfig()
+	lanConfig := lan.DefaultConfig()
+	logConfig := middleware.DefaultLogConfig()
+	loggingConfig := logging.DefaultConfig()
+	limits := serverConfig.Limits
+	sinks := []Sink{}
+	for _, sink := range loggingConfig.Sinks {
+		sinks = append(sinks, Sink{Type: sink.Type, Level: sink.Level, Format: sink.Format})
+	}
+	return Config{
+		Server: Server{
+			Addr:              serverConfig.Addr,
+			TLSCertFile:       serverConfig.TLSCertFile,
+			TLSKeyFile:        serverConfig.TLSKeyFile,
+			TemplatesDir:      serverConfig.TemplatesDir,
+			StateFile:         "party-game-state.json",
+			ReadHeaderTimeout: serverConfig.ReadHeaderTimeout,
+			ReadTimeout:       serverConfig.ReadTimeout,
+			WriteTimeout:      serverConfig.WriteTimeout,
+			IdleTimeout:       serverConfig.IdleTimeout,
+			ShutdownTimeout:   serverConfig.ShutdownTimeout,
+			LongPollTimeout:   serverConfig.LongPollTimeout,
+		},
+		Logging: Logging{
+			Level:   loggingConfig.Level,
+			Sinks:   sinks,
+			Redact:  logConfig.Redact,
+			LogForm: logConfig.LogForm,
+		},
+		Game: Game{
+			AnswerMinLength: gameConfig.Answers.MinLength,
+			AnswerMaxLength: gameConfig.Answers.MaxLength,
+			FilterProfanity: gameConfig.Answers.FilterProfanity,
+			JanitorInterval: gameConfig.Expiry.Interval,
+			IdleGameTTL:     gameConfig.Expiry.IdleGameTTL,
+			FinishedGameTTL: gameConfig.Expiry.FinishedGameTTL,
+			PlayerTTL:       gameConfig.Expiry.PlayerTTL,
+			HistoryTTL:      gameConfig.Expiry.HistoryTTL,
+			MinPlayers:      gameConfig.Players.MinPlayers,
+			MaxPlayers:      gameConfig.Players.MaxPlayers,
+			LateJoin:        gameConfig.Players.LateJoin,
+			AwayAfter:       gameConfig.Players.AwayAfter,
+			RoomSecret:      gameConfig.RoomSecret,
+		},
+		LAN: LAN{
+			Enabled:   lanConfig.Enabled,
+			Interface: lanConfig.Interface,
+			Hostname:  lanConfig.Hostname,
+			Instance:  lanConfig.Instance,
+		},
+		Admin: Admin{
+			Token: serverConfig.AdminToken,
+			Pprof: serverConfig.Pprof,
+		},
+		Limits: Limits{
+			CreatePlayerPerMinute: int(limits.CreatePlayer.PerMinute),
+			CreatePlayerBurst:     limits.CreatePlayer.Burst,
+			JoinPerMinute:         int(limits.Join.PerMinute),
+			JoinBurst:             limits.Join.Burst,
+			SubmitPerMinute:       int(limits.Submit.PerMinute),
+			SubmitBurst:           limits.Submit.Burst,
+			BadPasswordAttempts:   limits.BadPasswordAttempts,
+			BadPasswordLockout:    limits.BadPasswordLockout,
+			MaxGamesPerIP:         limits.MaxGamesPerIP,
+		},
+	}
+}
+
+// Load starts from the defaults, applies the TOML file at path if path is not
+// empty, then the environment overrides, and validates the result.
+func Load(path string) (Config, error) {
+	config := Default()
+	if path != "" {
+		metadata, err := toml.DecodeFile(path, &config)
+		if err != nil {
+			return Config{}, fmt.Errorf("reading config %s: %w", path, err)
+		}
+		if undecoded := metadata.Undecoded(); len(undecoded) > 0 {
+			return Config{}, fmt.Errorf("unknown settings in config %s: %v", path, undecoded)
+		}
+	}
+	if err := applyEnv(&config, os.LookupEnv); err != nil {
+		return Config{}, err
+	}
+	if err := config.Validate(); err != nil {
+		return Config{}, err
+	}
+	return config, nil
+}
+
+func (c Config) Validate() error {
+	errs := []error{}
+	if c.Server.Addr == "" {
+		errs = append(errs, errors.New("server.addr is required"))
+	}
+	if (c.Server.TLSCertFile == "") != (c.Server.TLSKeyFile == "") {
+		errs = append(errs, errors.New("server.tls_cert_file and server.tls_key_file must be set together"))
+	}
+	timeouts := map[string]time.Duration{
+		"server.read_header_timeout": c.Server.ReadHeaderTimeout,
+		"server.read_timeout":        c.Server.ReadTimeout,
+		"server.write_timeout":       c.Server.WriteTimeout,
+		"server.idle_timeout":        c.Server.IdleTimeout,
+		"server.shutdown_timeout":    c.Server.ShutdownTimeout,
+	}
+	for name, timeout := range timeouts {
+		if timeout < 0 {
+			errs = append(errs, errors.New(name+" cannot be negative"))
+		}
+	}
+	if c.Server.LongPollTimeout <= 0 {
+		errs = append(errs, errors.New("server.long_poll_timeout must be positive"))
+	}
+	if c.Server.WriteTimeout > 0 && c.Server.WriteTimeout <= c.Server.LongPollTimeout {
+		errs = append(errs, errors.New("server.write_timeout must be 0 or longer than server.long_poll_timeout"))
+	}
+	if err := c.Logging.LoggingConfig().Validate(); err != nil {
+		errs = append(errs, fmt.Errorf("logging: %w", err))
+	}
+	if c.Game.AnswerMinLength < 1 {
+		errs = append(errs, errors.New("game.answer_min_length must be at least 1"))
+	}
+	if c.Game.AnswerMaxLength < c.Game.AnswerMinLength {
+		errs = append(errs, errors.New("game.answer_max_length must not be smaller than game.answer_min_length"))
+	}
+	if c.Game.JanitorInterval < 0 {
+		errs = append(errs, errors.New("game.janitor_interval cannot be negative"))
+	}
+	ttls := map[string]time.Duration{
+		"game.idle_game_ttl":     c.Game.IdleGameTTL,
+		"game.finished_game_ttl": c.Game.FinishedGameTTL,
+		"game.player_ttl":        c.Game.PlayerTTL,
+		"game.history_ttl":       c.Game.HistoryTTL,
+	}
+	for name, ttl := range ttls {
+		if c.Game.JanitorInterval > 0 && ttl <= 0 {
+			errs = append(errs, errors.New(name+" must be positive while the janitor runs"))
+		}
+	}
+	if c.Game.MinPlayers < 2 {
+		errs = append(errs, errors.New("game.min_players must be at least 2"))
+	}
+	if c.Game.MaxPlayers < c.Game.MinPlayers {
+		errs = append(errs, errors.New("game.max_players_per_game must be at least game.min_players"))
+	}
+	// Pages send a heartbeat every 5s and event streams every 10s
+	if c.Game.AwayAfter < 0 || (c.Game.AwayAfter > 0 && c.Game.AwayAfter < 15*time.Second) {
+		errs = append(errs, errors.New("game.away_after must be 0 or at least 15s"))
+	}
+	if c.Game.RoomSecret != "" && len(c.Game.RoomSecret) < 16 {
+		errs = append(errs, errors.New("game.room_secret must be empty or at least 16 characters"))
+	}
+	if !gamelogic.IsLateJoinPolicy(c.Game.LateJoin) {
+		errs = append(errs, fmt.Errorf("game.late_join %q must be %s, %s or %s",
+			c.Game.LateJoin, gamelogic.LateJoinReject, gamelogic.LateJoinSpectate, gamelogic.LateJoinImmediate))
+	}
+	limits := map[string]int{
+		"limits.create_player_per_minute": c.Limits.CreatePlayerPerMinute,
+		"limits.create_player_burst":      c.Limits.CreatePlayerBurst,
+		"limits.join_per_minute":          c.Limits.JoinPerMinute,
+		"limits.join_burst":               c.Limits.JoinBurst,
+		"limits.submit_per_minute":        c.Limits.SubmitPerMinute,
+		"limits.submit_burst":             c.Limits.SubmitBurst,
+		"limits.bad_password_attempts":    c.Limits.BadPasswordAttempts,
+		"limits.max_games_per_ip":         c.Limits.MaxGamesPerIP,
+	}
+	for name, limit := range limits {
+		if limit < 0 {
+			errs = append(errs, errors.New(name+" cannot be negative"))
+		}
+	}
+	if c.Limits.BadPasswordAttempts > 0 && c.Limits.BadPasswordLockout <= 0 {
+		errs = append(errs, errors.New("limits.bad_password_lockout must be positive while bad_password_attempts is set"))
+	}
+	if c.LAN.Enabled && (c.LAN.Hostname == "" || strings.ContainsAny(c.LAN.Hostname, ". ")) {
+		errs = append(errs, errors.New("lan.hostname must be a single name without dots, it is advertised as <hostname>.local"))
+	}
+	if c.LAN.Enabled && c.LAN.Instance == "" {
+		errs = append(errs, errors.New("lan.instance is required"))
+	}
+	if c.Admin.Pprof && c.Admin.Token == "" {
+		errs = append(errs, errors.New("admin.pprof needs admin.token, the profiler is never served without it"))
+	}
+	return errors.Join(errs...)
+}
+
+func (l Logging) LoggingConfig() logging.Config {
+	sinks := []logging.SinkConfig{}
+	for _, sink := range l.Sinks {
+		sinks = append(sinks, logging.SinkConfig{
+			Type:       sink.Type,
+			Level:      sink.Level,
+			Format:     sink.Format,
+			Path:       sink.Path,
+			MaxSizeMB:  sink.MaxSizeMB,
+			MaxBackups: sink.MaxBackups,
+			Addr:       sink.Addr,
+			Protocol:   sink.Protocol,
+		})
+	}
+	return logging.Config{Level: l.Level, Sinks: sinks}
+}
+
+func (l Logging) RequestLogConfig() middleware.LogConfig {
+	return middleware.LogConfig{
+		Redact:    l.Redact,
+		LogForm:   l.LogForm,
+		Correlate: handlers.RequestAttrs,
+	}
+}
+
+func (c Config) ServerConfig() server.Config {
+	return server.Config{
+		Addr:              c.Server.Addr,
+		TLSCertFile:       c.Server.TLSCertFile,
+		TLSKeyFile:        c.Server.TLSKeyFile,
+		TemplatesDir:      c.Server.TemplatesDir,
+		StateFile:         c.Server.StateFile,
+		ReadHeaderTimeout: c.Server.ReadHeaderTimeout,
+		ReadTimeout:       c.Server.ReadTimeout,
+		WriteTimeout:      c.Server.WriteTimeout,
+		IdleTimeout:       c.Server.IdleTimeout,
+		ShutdownTimeout:   c.Server.ShutdownTimeout,
+		LongPollTimeout:   c.Server.LongPollTimeout,
+		AdminToken:        c.Admin.Token,
+		Pprof:             c.Admin.Pprof,
+		Limits: handlers.LimitConfig{
+			CreatePlayer:        ratelimit.Rate{PerMinute: float64(c.Limits.CreatePlayerPerMinute), Burst: c.Limits.CreatePlayerBurst},
+			Join:                ratelimit.Rate{PerMinute: float64(c.Limits.JoinPerMinute), Burst: c.Limits.JoinBurst},
+			Submit:              ratelimit.Rate{PerMinute: float64(c.Limits.SubmitPerMinute), Burst: c.Limits.SubmitBurst},
+			BadPasswordAttempts: c.Limits.BadPasswordAttempts,
+			BadPasswordLockout:  c.Limits.BadPasswordLockout,
+			MaxGamesPerIP:       c.Limits.MaxGamesPerIP,
+		},
+		Game: c.GameConfig(),
+		LAN: lan.Config{
+			Enabled:   c.LAN.Enabled,
+			Interface: c.LAN.Interface,
+			Hostname:  c.LAN.Hostname,
+			Instance:  c.LAN.Instance,
+		},
+	}
+}
+
+func (c Config) GameConfig() gamelogic.Config {
+	return gamelogic.Config{
+		Answers: gamelogic.AnswerRules{
+			MinLength:       c.Game.AnswerMinLength,
+			MaxLength:       c.Game.AnswerMaxLength,
+			FilterProfanity: c.Game.FilterProfanity,
+		},
+		Expiry: gamelogic.ExpiryRules{
+			Interval:        c.Game.JanitorInterval,
+			IdleGameTTL:     c.Game.IdleGameTTL,
+			FinishedGameTTL: c.Game.FinishedGameTTL,
+			PlayerTTL:       c.Game.PlayerTTL,
+			HistoryTTL:      c.Game.HistoryTTL,
+		},
+		Players: gamelogic.PlayerRules{
+			MinPlayers: c.Game.MinPlayers,
+			MaxPlayers: c.Game.MaxPlayers,
+			LateJoin:   c.Game.LateJoin,
+			AwayAfter:  c.Game.AwayAfter,
+		},
+		RoomSecret: c.Game.RoomSecret,
+	}
+}
+
+// applyEnv overrides every setting that has a matching environment variable.
+// The variable names are derived from the toml tags of the sections.
+func applyEnv(config *Config, lookup func(string) (string, bool)) error {
+	sections := reflect.ValueOf(config).Elem()
+	for i := 0; i < sections.NumField(); i++ {
+		section := sections.Field(i)
+		sectionName := sections.Type().Field(i).Tag.Get("toml")
+		for j := 0; j < section.NumField(); j++ {
+			field := section.Field(j)
+			key := section.Type().Field(j).Tag.Get("toml")
+			name := envPrefix + "_" + strings.ToUpper(sectionName) + "_" + strings.ToUpper(key)
+			value, ok := lookup(name)
+			if !ok {
+				continue
+			}
+			if err := setField(field, value); err != nil {
+				return fmt.Errorf("environment variable %s: %w", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func setField(field reflect.Value, value string) error {
+	if field.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String {
+			return errors.New("unsupported setting type " + field.Type().String())
+		}
+		items := []string{}
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	case reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	default:
+		return errors.New("unsupported setting type " + field.Type().String())
+	}
+	return nil
+}
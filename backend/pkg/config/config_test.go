@@ -0,0 +1,157 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestApplyEnv(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     map[string]string
+		check   func(c Config) bool
+		wantErr string
+	}{
+		{
+			name:  "string",
+			env:   map[string]string{"PARTYGAME_SERVER_ADDR": ":9999"},
+			check: func(c Config) bool { return c.Server.Addr == ":9999" },
+		},
+		{
+			name:  "duration",
+			env:   map[string]string{"PARTYGAME_GAME_AWAY_AFTER": "45s"},
+			check: func(c Config) bool { return c.Game.AwayAfter == 45*time.Second },
+		},
+		{
+			name:  "int",
+			env:   map[string]string{"PARTYGAME_LIMITS_JOIN_BURST": "7"},
+			check: func(c Config) bool { return c.Limits.JoinBurst == 7 },
+		},
+		{
+			name:  "bool",
+			env:   map[string]string{"PARTYGAME_GAME_FILTER_PROFANITY": "true"},
+			check: func(c Config) bool { return c.Game.FilterProfanity },
+		},
+		{
+			name: "list drops empty items",
+			env:  map[string]string{"PARTYGAME_LOGGING_REDACT": "game-password, ,player-answer"},
+			check: func(c Config) bool {
+				return len(c.Logging.Redact) == 2 && c.Logging.Redact[0] == "game-password" && c.Logging.Redact[1] == "player-answer"
+			},
+		},
+		{
+			name:  "unrelated variables are ignored",
+			env:   map[string]string{"PARTYGAME_SERVER_PORT": "1"},
+			check: func(c Config) bool { return c.Server.Addr == Default().Server.Addr },
+		},
+		{
+			name:    "bad duration",
+			env:     map[string]string{"PARTYGAME_GAME_AWAY_AFTER": "soon"},
+			wantErr: "PARTYGAME_GAME_AWAY_AFTER",
+		},
+		{
+			name:    "bad int",
+			env:     map[string]string{"PARTYGAME_LIMITS_JOIN_BURST": "many"},
+			wantErr: "PARTYGAME_LIMITS_JOIN_BURST",
+		},
+		{
+			name:    "sinks are file only",
+			env:     map[string]string{"PARTYGAME_LOGGING_SINKS": "stdout"},
+			wantErr: "unsupported setting type",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := Default()
+			lookup := func(name string) (string, bool) {
+				value, ok := tt.env[name]
+				return value, ok
+			}
+			err := applyEnv(&config, lookup)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("applyEnv() = %v, want an error mentioning %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("applyEnv() = %v", err)
+			}
+			if !tt.check(config) {
+				t.Errorf("applyEnv() did not apply %v", tt.env)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		change  func(c *Config)
+		wantErr string
+	}{
+		{name: "defaults", change: func(c *Config) {}},
+		{name: "no addr", change: func(c *Config) { c.Server.Addr = "" }, wantErr: "server.addr"},
+		{name: "cert without key", change: func(c *Config) { c.Server.TLSCertFile = "cert.pem" }, wantErr: "tls_key_file"},
+		{
+			name: "write timeout cuts long polls",
+			change: func(c *Config) {
+				c.Server.LongPollTimeout = time.Minute
+				c.Server.WriteTimeout = 30 * time.Second
+			},
+			wantErr: "server.write_timeout",
+		},
+		{name: "no write timeout", change: func(c *Config) { c.Server.WriteTimeout = 0 }},
+		{name: "max below min length", change: func(c *Config) { c.Game.AnswerMaxLength = 0 }, wantErr: "answer_max_length"},
+		{name: "ttl while janitor runs", change: func(c *Config) { c.Game.HistoryTTL = 0 }, wantErr: "game.history_ttl"},
+		{
+			name: "ttl without janitor",
+			change: func(c *Config) {
+				c.Game.JanitorInterval = 0
+				c.Game.HistoryTTL = 0
+			},
+		},
+		{name: "one player", change: func(c *Config) { c.Game.MinPlayers = 1 }, wantErr: "game.min_players"},
+		{name: "max below min players", change: func(c *Config) { c.Game.MaxPlayers = 1 }, wantErr: "max_players_per_game"},
+		{name: "away too soon", change: func(c *Config) { c.Game.AwayAfter = 5 * time.Second }, wantErr: "game.away_after"},
+		{name: "away disabled", change: func(c *Config) { c.Game.AwayAfter = 0 }},
+		{name: "short room secret", change: func(c *Config) { c.Game.RoomSecret = "party" }, wantErr: "game.room_secret"},
+		{name: "room secret", change: func(c *Config) { c.Game.RoomSecret = "a long enough secret" }},
+		{name: "unknown late join", change: func(c *Config) { c.Game.LateJoin = "maybe" }, wantErr: "game.late_join"},
+		{name: "negative limit", change: func(c *Config) { c.Limits.JoinBurst = -1 }, wantErr: "limits.join_burst"},
+		{
+			name: "lockout without duration",
+			change: func(c *Config) {
+				c.Limits.BadPasswordAttempts = 3
+				c.Limits.BadPasswordLockout = 0
+			},
+			wantErr: "bad_password_lockout",
+		},
+		{
+			name: "lan hostname with dots",
+			change: func(c *Config) {
+				c.LAN.Enabled = true
+				c.LAN.Hostname = "party.local"
+			},
+			wantErr: "lan.hostname",
+		},
+		{name: "pprof without token", change: func(c *Config) { c.Admin.Pprof = true }, wantErr: "admin.pprof"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := Default()
+			tt.change(&config)
+			err := config.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() = %v, want an error mentioning %q", err, tt.wantErr)
+			}
+		})
+	}
+}
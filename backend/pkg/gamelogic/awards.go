@@ -0,0 +1,140 @@
+package gamelogic
+
+import (
+	"strconv"
+	"unicode/utf8"
+)
+
+// Award is a highlight of a finished game, handed out from the round data.
+type Award struct {
+	Title       string
+	Description string // what the award is given for
+	PlayerId    string
+	PlayerName  string
+	Detail      string // why the player got it, e.g. the answer and its votes
+}
+
+// computeAwards picks the highlights of the night. An award nobody earned,
+// e.g. the crowd pleaser of a game without votes, is left out. Ties go to
+// whoever got there first.
+func computeAwards(archived *ArchivedGame) []Award {
+	awards := []Award{}
+	for _, award := range []func(*ArchivedGame) (Award, bool){crowdPleaser, steadyHand, toughCrowd, hotSeat} {
+		if a, ok := award(archived); ok {
+			awards = append(awards, a)
+		}
+	}
+	return awards
+}
+
+// crowdPleaser goes to the answer with the most votes in a single round.
+func crowdPleaser(archived *ArchivedGame) (Award, bool) {
+	var best *ArchivedAnswer
+	for i := range archived.Rounds {
+		for j := range archived.Rounds[i].Answers {
+			a := &archived.Rounds[i].Answers[j]
+			if len(a.VoterIds) > 0 && (best == nil || len(a.VoterIds) > len(best.VoterIds)) {
+				best = a
+			}
+		}
+	}
+	if best == nil {
+		return Award{}, false
+	}
+	return Award{
+		Title:       "Crowd pleaser",
+		Description: "Most votes for a single answer",
+		PlayerId:    best.AuthorId,
+		PlayerName:  best.AuthorName,
+		Detail:      strconv.Quote(best.Text) + " got " + plural(len(best.VoterIds), "vote"),
+	}, true
+}
+
+// steadyHand goes to the player who scored in the most rounds.
+func steadyHand(archived *ArchivedGame) (Award, bool) {
+	scored := map[string]int{}
+	for _, round := range archived.Rounds {
+		for _, a := range round.Answers {
+			if a.Points > 0 {
+				scored[a.AuthorId]++
+			}
+		}
+	}
+	best := ArchivedPlayer{}
+	for _, p := range archived.Players {
+		if scored[p.Id] > scored[best.Id] {
+			best = p
+		}
+	}
+	// Scoring once is luck, not consistency
+	if scored[best.Id] < 2 {
+		return Award{}, false
+	}
+	return Award{
+		Title:       "Steady hand",
+		Description: "Scored in the most rounds",
+		PlayerId:    best.Id,
+		PlayerName:  best.Name,
+		Detail:      "Scored in " + strconv.Itoa(scored[best.Id]) + " of " + plural(len(archived.Rounds), "round"),
+	}, true
+}
+
+// toughCrowd goes to the longest answer nobody voted for, the most effort
+// that went unrewarded.
+func toughCrowd(archived *ArchivedGame) (Award, bool) {
+	var best *ArchivedAnswer
+	for i := range archived.Rounds {
+		for j := range archived.Rounds[i].Answers {
+			a := &archived.Rounds[i].Answers[j]
+			if len(a.VoterIds) == 0 && (best == nil || utf8.RuneCountInString(a.Text) > utf8.RuneCountInString(best.Text)) {
+				best = a
+			}
+		}
+	}
+	if best == nil {
+		return Award{}, false
+	}
+	return Award{
+		Title:       "Tough crowd",
+		Description: "An answer nobody voted for",
+		PlayerId:    best.AuthorId,
+		PlayerName:  best.AuthorName,
+		Detail:      strconv.Quote(best.Text) + " got no votes",
+	}, true
+}
+
+// hotSeat goes to the player whose answers got the most votes in the rounds
+// whose question was about them.
+func hotSeat(archived *ArchivedGame) (Award, bool) {
+	votes := map[string]int{}
+	for _, round := range archived.Rounds {
+		for _, a := range round.Answers {
+			if a.AuthorId == round.SubjectId {
+				votes[a.AuthorId] += len(a.VoterIds)
+			}
+		}
+	}
+	best := ArchivedPlayer{}
+	for _, p := range archived.Players {
+		if votes[p.Id] > votes[best.Id] {
+			best = p
+		}
+	}
+	if votes[best.Id] == 0 {
+		return Award{}, false
+	}
+	return Award{
+		Title:       "Hot seat",
+		Description: "Most votes when the question was about them",
+		PlayerId:    best.Id,
+		PlayerName:  best.Name,
+		Detail:      plural(votes[best.Id], "vote") + " for answers about themselves",
+	}, true
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return "1 " + word
+	}
+	return strconv.Itoa(n) + " " + word + "s"
+}
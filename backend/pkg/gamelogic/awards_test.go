@@ -0,0 +1,89 @@
+package gamelogic
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestComputeAwards(t *testing.T) {
+	ann := ArchivedPlayer{Id: "ann", Name: "Ann"}
+	bob := ArchivedPlayer{Id: "bob", Name: "Bob"}
+	cat := ArchivedPlayer{Id: "cat", Name: "Cat"}
+	// answer is the answer of the author with a vote of each voter
+	answer := func(author ArchivedPlayer, text string, voters ...ArchivedPlayer) ArchivedAnswer {
+		a := ArchivedAnswer{Id: author.Id + text, Text: text, AuthorId: author.Id, AuthorName: author.Name, Points: len(voters)}
+		for _, v := range voters {
+			a.VoterIds = append(a.VoterIds, v.Id)
+			a.VoterNames = append(a.VoterNames, v.Name)
+		}
+		return a
+	}
+	round := func(subject ArchivedPlayer, answers ...ArchivedAnswer) ArchivedRound {
+		return ArchivedRound{Question: "?", SubjectId: subject.Id, Answers: answers}
+	}
+
+	tests := []struct {
+		name   string
+		rounds []ArchivedRound
+		want   []string // title: player - detail
+	}{
+		{
+			name:   "no rounds, no awards",
+			rounds: []ArchivedRound{},
+			want:   []string{},
+		},
+		{
+			name: "nobody voted, only the longest answer is left",
+			rounds: []ArchivedRound{
+				round(cat, answer(ann, "tacos"), answer(bob, "a very long answer")),
+			},
+			want: []string{`Tough crowd: Bob - "a very long answer" got no votes`},
+		},
+		{
+			name: "ties go to whoever got there first",
+			rounds: []ArchivedRound{
+				round(cat, answer(ann, "tacos", bob, cat), answer(bob, "sushi")),
+				round(cat, answer(bob, "ramen", ann, cat), answer(ann, "pho")),
+			},
+			want: []string{
+				`Crowd pleaser: Ann - "tacos" got 2 votes`,
+				`Tough crowd: Bob - "sushi" got no votes`,
+			},
+		},
+		{
+			name: "scoring in two rounds is steady",
+			rounds: []ArchivedRound{
+				round(cat, answer(ann, "tacos", bob), answer(bob, "sushi", cat)),
+				round(cat, answer(ann, "ramen", cat, bob), answer(bob, "pho")),
+			},
+			want: []string{
+				`Crowd pleaser: Ann - "ramen" got 2 votes`,
+				`Steady hand: Ann - Scored in 2 of 2 rounds`,
+				`Tough crowd: Bob - "pho" got no votes`,
+			},
+		},
+		{
+			name: "votes for answering a question about yourself",
+			rounds: []ArchivedRound{
+				round(bob, answer(ann, "tacos", cat), answer(bob, "sushi", ann)),
+				round(ann, answer(cat, "ramen", ann, bob)),
+			},
+			want: []string{
+				`Crowd pleaser: Cat - "ramen" got 2 votes`,
+				`Hot seat: Bob - 1 vote for answers about themselves`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archived := ArchivedGame{Players: []ArchivedPlayer{ann, bob, cat}, Rounds: tt.rounds}
+			got := []string{}
+			for _, a := range computeAwards(&archived) {
+				got = append(got, a.Title+": "+a.PlayerName+" - "+a.Detail)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("computeAwards() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package gamelogic
+
+import (
+	"errors"
+	"log/slog"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	BotVoteRandom string = "random" // vote for any answer that is not the bot's own
+	BotVoteHumans string = "humans" // prefer answers written by human players
+)
+
+const MaxBotsPerGame int = 8
+
+var botNames = []string{"Robo Rita", "Bolt", "Sir Beeps", "Pixel", "Gizmo", "Clank", "Widget", "Sprocket"}
+
+// cannedAnswers holds the answers bots pick from, keyed by question pack.
+var cannedAnswers = map[string][]string{
+	defaultQuestionPack: {
+		"A rubber duck",
+		"Pineapple pizza",
+		"Interpretive dance",
+		"A suspiciously large sandwich",
+		"Their grandma's slippers",
+		"A haunted toaster",
+		"Yodeling lessons",
+		"A pet rock named Steve",
+		"Karaoke at 3am",
+		"Socks with sandals",
+		"A llama in a tuxedo",
+		"Napping professionally",
+		"Glitter. So much glitter.",
+		"A time-travelling hamster",
+		"Cold spaghetti",
+		"Whatever is in the fridge",
+		"A very dramatic sneeze",
+		"Winning an argument with a pigeon",
+		"Building a blanket fort",
+		"A kazoo solo",
+	},
+}
+
+const defaultQuestionPack string = "default"
+
+// ValidateBots checks the bots asked for with a new game before the game is
+// created with the rules, next to the player creating it.
+func ValidateBots(rules Rules, count int, voteStrategy string) error {
+	if voteStrategy != BotVoteRandom && voteStrategy != BotVoteHumans {
+		return errors.New("Unknown bot vote strategy " + voteStrategy + ".")
+	}
+	limit := min(MaxBotsPerGame, rules.Players.MaxPlayers-1)
+	if count < 0 || count > limit {
+		return errors.New("Number of bots must be between 0 and " + strconv.Itoa(limit) + ".")
+	}
+	return nil
+}
+
+// AddBot creates a server-side player that answers and votes on its own and
+// adds it to the game.
+func AddBot(gameId string, voteStrategy string) (Player, error) {
+	stateLock.Lock()
+	defer stateLock.Unlock()
+	game, ok := games[gameId]
+	if !ok {
+		return Player{}, errors.New("Game " + gameId + " does not exist")
+	}
+	if voteStrategy != BotVoteRandom && voteStrategy != BotVoteHumans {
+		return Player{}, errors.New("Unknown bot vote strategy " + voteStrategy)
+	}
+
+	botCount := 0
+	for _, p := range game.Players {
+		if p.IsBot {
+			botCount++
+		}
+	}
+	if len(game.Players) >= game.Rules.Players.MaxPlayers {
+		return Player{}, ErrGameFull
+	}
+	if botCount >= MaxBotsPerGame {
+		return Player{}, errors.New("Game already has " + strconv.Itoa(MaxBotsPerGame) + " bots")
+	}
+
+	bot := Player{
+		Id:          uuid.New().String(),
+		Name:        botNames[botCount%len(botNames)],
+		IsBot:       true,
+		BotStrategy: voteStrategy,
+		PlayerReady: true,
+		LastSeen:    time.Now(),
+	}
+	players[bot.Id] = bot
+	activePlayers.Inc()
+	addPlayerToGame(gameId, bot)
+	slog.Info("Added bot", "bot", bot, "gameId", gameId)
+
+	// Let the bot take part in the round that is already running
+	if round, err := latestRound(gameId); err == nil && !allPlayerAnswered(gameId, round.Id) {
+		botAnswer(gameId, round.Id, bot)
+	}
+	return bot, nil
+}
+
+// botsAnswer submits an answer for every bot in the game.
+func botsAnswer(gameId string, roundId string) {
+	for _, p := range games[gameId].Players {
+		if p.IsBot {
+			botAnswer(gameId, roundId, p)
+		}
+	}
+}
+
+func botAnswer(gameId string, roundId string, bot Player) {
+	answers := cannedAnswers[defaultQuestionPack]
+	// Try answers in random order, another player may already have used some of them
+	for _, i := range rand.Perm(len(answers)) {
+		err := addAnswer(gameId, bot.Id, roundId, answers[i])
+		if err == nil {
+			return
+		}
+		var validationErr *ValidationError
+		if !errors.As(err, &validationErr) {
+			slog.Error("Bot could not answer", "bot", bot, "error", err)
+			return
+		}
+	}
+	slog.Error("Bot ran out of canned answers", "bot", bot, "roundId", roundId)
+}
+
+// botsVote makes every bot in the game pick an answer of the round.
+func botsVote(gameId string, roundId string) {
+	game := games[gameId]
+	for _, p := range game.Players {
+		if !p.IsBot {
+			continue
+		}
+		for _, r := range game.Rounds {
+			if r.Id != roundId {
+				continue
+			}
+			choice, ok := botChoice(r, p)
+			if !ok {
+				slog.Debug("Bot has nothing to vote for", "bot", p, "roundId", roundId)
+				continue
+			}
+			if err := addChoice(gameId, p.Id, roundId, choice.Id); err != nil {
+				slog.Error("Bot could not vote", "bot", p, "error", err)
+			}
+		}
+	}
+}
+
+func botChoice(round Round, bot Player) (Answer, bool) {
+	candidates := []Answer{}
+	humanCandidates := []Answer{}
+	for _, a := range round.Answers {
+		if a.Owner.Id == bot.Id {
+			continue
+		}
+		candidates = append(candidates, a)
+		if !a.Owner.IsBot {
+			humanCandidates = append(humanCandidates, a)
+		}
+	}
+
+	if bot.BotStrategy == BotVoteHumans && len(humanCandidates) > 0 {
+		candidates = humanCandidates
+	}
+	if len(candidates) == 0 {
+		return Answer{}, false
+	}
+	return candidates[rand.Intn(len(candidates))], true
+}
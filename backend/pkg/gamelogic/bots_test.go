@@ -0,0 +1,37 @@
+package gamelogic
+
+import "testing"
+
+func TestValidateBots(t *testing.T) {
+	resetState(t)
+	config := testConfig()
+	config.Players.MaxPlayers = 6
+	tests := []struct {
+		name     string
+		count    int
+		strategy string
+		ok       bool
+	}{
+		{name: "no bots", count: 0, strategy: BotVoteRandom, ok: true},
+		{name: "room left for the player", count: 5, strategy: BotVoteHumans, ok: true},
+		{name: "no room left for the player", count: 6, strategy: BotVoteRandom, ok: false},
+		{name: "negative", count: -1, strategy: BotVoteRandom, ok: false},
+		{name: "unknown strategy", count: 1, strategy: "smart", ok: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateBots(config.Rules(), tt.count, tt.strategy); (err == nil) != tt.ok {
+				t.Errorf("ValidateBots(%d, %q) = %v, want ok %v", tt.count, tt.strategy, err, tt.ok)
+			}
+		})
+	}
+
+	// Whatever passes fits into a new game
+	player, _ := CreatePlayer("Ann")
+	game, _ := CreateGame(config, "pizza", player.Id, DefaultGameMode)
+	for i := 0; i < 5; i++ {
+		if _, err := AddBot(game.Id, BotVoteRandom); err != nil {
+			t.Fatalf("AddBot() = %v after %d bots", err, i)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package gamelogic
+
+import "time"
+
+type Config struct {
+	Answers AnswerRules
+	Expiry  ExpiryRules
+	Players PlayerRules
+	// RoomSecret keys the index of the game passwords. Callers make up a
+	// random one with NewRoomSecret when none is configured.
+	RoomSecret string
+}
+
+// Rules are what a game is played by. A game keeps the rules it was created
+// with, so changing the config never changes a running game.
+type Rules struct {
+	Answers AnswerRules
+	Players PlayerRules
+}
+
+func (c Config) Rules() Rules {
+	return Rules{Answers: c.Answers, Players: c.Players}
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Answers: AnswerRules{
+			MinLength:       1,
+			MaxLength:       140,
+			FilterProfanity: false,
+		},
+		Expiry: ExpiryRules{
+			Interval:        time.Minute,
+			IdleGameTTL:     time.Hour,
+			FinishedGameTTL: time.Hour,
+			PlayerTTL:       time.Hour,
+			HistoryTTL:      7 * 24 * time.Hour,
+		},
+		Players: PlayerRules{
+			MinPlayers: 2,
+			MaxPlayers: 50,
+			LateJoin:   LateJoinSpectate,
+			AwayAfter:  30 * time.Second,
+		},
+	}
+}
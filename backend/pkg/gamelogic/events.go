@@ -0,0 +1,87 @@
+package gamelogic
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	EventPlayerJoined    string = "player-joined"
+	EventRoundStarted    string = "round-started"
+	EventAnswerSubmitted string = "answer-submitted"
+	EventVotingStarted   string = "voting-started"
+	EventVoteSubmitted   string = "vote-submitted"
+	EventRoundFinished   string = "round-finished"
+	EventPlayerReady     string = "player-ready"
+	EventGameFinished    string = "game-finished"
+	EventGameExpired     string = "game-expired" // ended by the janitor after being idle
+	EventPlayerAway      string = "player-away"  // not seen for the grace period, rounds stop waiting for them
+	EventPlayerBack      string = "player-back"
+)
+
+type Event struct {
+	Type     string    `json:"type"`
+	GameId   string    `json:"gameId"`
+	RoundId  string    `json:"roundId,omitempty"`
+	PlayerId string    `json:"playerId,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+var subscribers map[string][]chan Event = make(map[string][]chan Event)
+var subscribersLock sync.Mutex
+
+// Subscribe returns a channel receiving every event of the game and a
+// function that must be called to stop the subscription.
+func Subscribe(gameId string) (<-chan Event, func()) {
+	ch := make(chan Event, 32)
+	subscribersLock.Lock()
+	subscribers[gameId] = append(subscribers[gameId], ch)
+	subscribersLock.Unlock()
+	eventStreams.Inc()
+
+	unsubscribe := func() {
+		subscribersLock.Lock()
+		defer subscribersLock.Unlock()
+		subs := subscribers[gameId]
+		for i, s := range subs {
+			if s == ch {
+				subscribers[gameId] = append(subs[:i], subs[i+1:]...)
+				close(ch)
+				eventStreams.Dec()
+				break
+			}
+		}
+		if len(subscribers[gameId]) == 0 {
+			delete(subscribers, gameId)
+		}
+	}
+	return ch, unsubscribe
+}
+
+// closeSubscribers ends every subscription of a game that is deleted, the
+// subscribers see their channel closed.
+func closeSubscribers(gameId string) {
+	subscribersLock.Lock()
+	defer subscribersLock.Unlock()
+	for _, ch := range subscribers[gameId] {
+		close(ch)
+		eventStreams.Dec()
+	}
+	delete(subscribers, gameId)
+}
+
+// publish sends the event to every subscriber of the game. Slow subscribers
+// miss events instead of blocking the game.
+func publish(eventType string, gameId string, roundId string, playerId string) {
+	event := Event{eventType, gameId, roundId, playerId, time.Now()}
+	subscribersLock.Lock()
+	defer subscribersLock.Unlock()
+	for _, ch := range subscribers[gameId] {
+		select {
+		case ch <- event:
+		default:
+			slog.Warn("Dropping event for slow subscriber", "event", event)
+		}
+	}
+}
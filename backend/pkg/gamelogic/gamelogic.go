@@ -3,142 +3,310 @@ package gamelogic
 import (
 	"errors"
 	"log/slog"
+	"maps"
+	"slices"
+	"sort"
 	"sync"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+// stateLock guards the games, players, rooms and history. The exported
+// functions take it, the unexported ones expect their caller to hold it.
+// Games handed out are copies, so callers can read them without the lock.
+var stateLock sync.RWMutex
+
 var games map[string]Game = make(map[string]Game)
 var players map[string]Player = make(map[string]Player)
 
-func CreateGame(password string, playerId string) (Game, bool) {
-	for _, v := range games {
-		if v.Password == password && !v.IsComplete {
-			return Game{}, false
-		}
-	}
+// Errors callers tell apart, e.g. to count wrong passwords.
+var (
+	ErrGameNotFound = errors.New("Game does not exist.")
+	ErrGameFull     = errors.New("Game is full.")
+	// ErrRoundInProgress is returned when a game is ended before the
+	// results of its latest round are out.
+	ErrRoundInProgress = errors.New("The round is still being played.")
+)
 
-	player := GetPlayer(playerId)
+// CreateGame starts a game with the rules of the config, which it keeps for
+// good, and indexes it by its password with the room secret of the config.
+func CreateGame(config Config, password string, playerId string, modeName string) (Game, bool) {
+	mode, ok := GetGameMode(modeName)
+	if !ok {
+		slog.Error("Game mode does not exist", "mode", modeName)
+		return Game{}, false
+	}
 
+	// Hashing the password is slow on purpose, it happens before taking the lock
+	if _, taken := findRoom(config.RoomSecret, password); taken {
+		return Game{}, false
+	}
 	game := Game{
 		Id:         uuid.New().String(),
-		Players:    []Player{player},
 		Rounds:     []Round{},
-		Password:   password,
 		Started:    false,
 		IsComplete: false,
-		Score:      make(map[string]int),
+		Mode:       mode.Name(),
+		Rules:      config.Rules(),
 	}
+	game.setPassword(config.RoomSecret, password)
 
+	stateLock.Lock()
+	defer stateLock.Unlock()
+	if _, taken := rooms[game.RoomKey]; taken {
+		return Game{}, false
+	}
+	game.Players = []Player{players[playerId]}
+	game.touch()
+	mode.Setup(&game)
 	games[game.Id] = game
-	CreateNewRound(game.Id)
+	rooms[game.RoomKey] = game.Id
+	gamesCreated.Inc()
+	activeGames.Inc()
+	createNewRound(game.Id)
 	slog.Info("Created game", "game", game)
-	return game, true
+	return game.clone(), true
 }
 
-func JoinGame(password string, playerId string) (Game, error) {
-	var game *Game
-	for _, g := range games {
-		if g.Password == password {
-			game = &g
-			break
-		}
+// JoinGame adds the player to the running game with the password, which is
+// looked up with the room secret of the config.
+func JoinGame(config Config, password string, playerId string) (Game, error) {
+	if GetPlayer(playerId).Id == "" {
+		slog.Error("Player does not exist", "playerId", playerId)
+		return Game{}, errors.New("Player does not exist.")
+	}
+	found, ok := findRoom(config.RoomSecret, password)
+	if !ok {
+		slog.Info("No running game with the password")
+		return Game{}, ErrGameNotFound
 	}
 
-	if game == nil {
-		slog.Error("Game does not exist", "requested-password", password)
-		return Game{}, errors.New("Game does not exist.")
+	stateLock.Lock()
+	defer stateLock.Unlock()
+	// The game may have ended while the password was checked
+	game, ok := games[found.Id]
+	if !ok || game.IsComplete {
+		return Game{}, ErrGameNotFound
+	}
+	if len(game.Players) >= game.Rules.Players.MaxPlayers {
+		slog.Info("Game is full", "gameId", game.Id, "players", len(game.Players))
+		return Game{}, ErrGameFull
+	}
+	if game.Started && game.Rules.Players.LateJoin == LateJoinReject {
+		slog.Info("Game already started", "gameId", game.Id)
+		return Game{}, ErrGameStarted
 	}
 
+	// The janitor may have deleted the player while the password was checked
 	player, playerExists := players[playerId]
 	if !playerExists {
 		slog.Error("Player does not exist", "requested-player", player)
 		return Game{}, errors.New("Player does not exist.")
 	}
 
-	AddPlayerToGame(game.Id, player)
+	addPlayerToGame(game.Id, player)
 
-	return *game, nil
+	return game.clone(), nil
 }
 
-func CreateNewRound(gameId string) {
+func createNewRound(gameId string) {
 	game := games[gameId]
-	round := Round{}
-	round.Id = uuid.New().String()
-	round.Question = GetRandomQuestion(game.GetNextPlayerName())
-	round.Answers = []Answer{}
+	if len(game.Rounds) > 0 {
+		observePhase(PhaseResults, game.Rounds[len(game.Rounds)-1].ResultsAt)
+	}
+	round := game.GameMode().NextRound(&game)
+	round.StartedAt = time.Now()
+	// Everybody plays the new round, late joiners included, and has to get
+	// ready again after it. Bots are always ready.
+	round.Participants = []string{}
+	for i := range game.Players {
+		round.Participants = append(round.Participants, game.Players[i].Id)
+		game.Players[i].PlayerReady = game.Players[i].IsBot
+	}
 	game.Rounds = append(game.Rounds, round)
+	game.touch()
 	games[gameId] = game
+	roundsCreated.Inc()
 	slog.Debug("Created new round", "game", game)
+	publish(EventRoundStarted, gameId, round.Id, "")
+	botsAnswer(gameId, round.Id)
 }
 
 func AllPlayerAnswered(gameId string, roundId string) bool {
+	stateLock.RLock()
+	defer stateLock.RUnlock()
+	return allPlayerAnswered(gameId, roundId)
+}
+
+func allPlayerAnswered(gameId string, roundId string) bool {
 	game := games[gameId]
 
-	for _, r := range game.Rounds {
-		slog.Debug("Checking all players answered", "round", r, "players", game.Players)
-		if r.Id == roundId {
-			if len(r.Answers) == len(game.Players) {
-				return true
-			}
+	for i := range game.Rounds {
+		if r := &game.Rounds[i]; r.Id == roundId {
+			return game.allAnswered(r)
 		}
 	}
 	return false
 }
 
 func AllPlayersSelectedChoice(gameId string, roundId string) bool {
+	stateLock.RLock()
+	defer stateLock.RUnlock()
+	return allPlayersSelectedChoice(gameId, roundId)
+}
+
+func allPlayersSelectedChoice(gameId string, roundId string) bool {
 	game := games[gameId]
 
-	for _, r := range game.Rounds {
-		slog.Debug("Checking all players selected choice", "round", r, "players", game.Players)
-		if r.Id == roundId {
-			if r.ChoiceCount == len(game.Players) {
-				return true
-			}
+	for i := range game.Rounds {
+		if r := &game.Rounds[i]; r.Id == roundId {
+			return game.allVoted(r)
 		}
 	}
 	return false
 }
 
+// AllPlayersReady reports whether the players of the latest round are all
+// ready for the next one.
 func AllPlayersReady(gameId string) bool {
+	stateLock.RLock()
+	defer stateLock.RUnlock()
 	game := games[gameId]
-	slog.Debug("Checking all players ready")
-	for _, p := range game.Players {
-		if !p.PlayerReady {
-			return false
-		}
+	if len(game.Rounds) == 0 {
+		return false
 	}
-	return true
+	return game.allReady(&game.Rounds[len(game.Rounds)-1])
 }
 
-var playerReadyLock sync.Mutex
-
 func PlayerReady(gameId string, playerId string) {
-	game := games[gameId]
-	playerReadyLock.Lock()
-	allPlayersReady := true
+	stateLock.Lock()
+	defer stateLock.Unlock()
+	game, ok := games[gameId]
+	if !ok {
+		slog.Error("Game does not exist", "gameId", gameId)
+		return
+	}
+	if game.IsComplete || len(game.Rounds) == 0 {
+		slog.Debug("Game is already complete", "gameId", gameId)
+		return
+	}
 	for i := range game.Players {
 		p := &game.Players[i]
 		if p.Id == playerId {
 			p.PlayerReady = true
 			slog.Info("Player is ready", "player", p)
-		}
-		if !p.PlayerReady {
-			allPlayersReady = false
+			publish(EventPlayerReady, gameId, "", playerId)
 		}
 	}
+	game.touch()
+	games[gameId] = game
+	nextRound(gameId)
+}
 
-	// if all players are ready start a  new round
-	if allPlayersReady {
-		slog.Debug("All players ready", "players", game.Players)
-		CreateNewRound(gameId)
-	} else {
+// nextRound starts a new round once the players of the latest one are ready,
+// unless the mode says the game is over.
+func nextRound(gameId string) {
+	game := games[gameId]
+	if game.IsComplete || len(game.Rounds) == 0 {
+		return
+	}
+	// Only a round everybody voted in is followed by the next one
+	latest := &game.Rounds[len(game.Rounds)-1]
+	if game.RoundPhase(latest) != PhaseResults || !game.allReady(latest) {
 		slog.Debug("Not all players ready", "players", game.Players)
+		return
+	}
+
+	if game.GameMode().IsFinished(&game) {
+		slog.Info("Game finished", "gameId", gameId, "mode", game.Mode)
+		finishGame(gameId)
+		return
+	}
+	slog.Debug("All players ready", "players", game.Players)
+	createNewRound(gameId)
+}
+
+// EndGame ends the game once the results of its latest round are out, which
+// is how classic games, that have no last round, get into the history. Any
+// player of the game can end it, ending it again does nothing.
+func EndGame(gameId string, playerId string) error {
+	stateLock.Lock()
+	defer stateLock.Unlock()
+	game, ok := games[gameId]
+	if !ok {
+		return ErrGameNotFound
+	}
+	if !slices.ContainsFunc(game.Players, func(p Player) bool { return p.Id == playerId }) {
+		return errors.New("Player " + playerId + " is not part of the game")
+	}
+	if game.IsComplete {
+		return nil
+	}
+	if len(game.Rounds) > 0 && game.RoundPhase(&game.Rounds[len(game.Rounds)-1]) != PhaseResults {
+		return ErrRoundInProgress
+	}
+	slog.Info("Game ended by a player", "gameId", gameId, "playerId", playerId, "mode", game.Mode)
+	finishGame(gameId)
+	return nil
+}
+
+// finishGame completes the game, frees its password and archives it.
+func finishGame(gameId string) {
+	game := games[gameId]
+	game.IsComplete = true
+	game.FinishedAt = time.Now()
+	games[gameId] = game
+	releaseRoom(game)
+	archiveGame(game)
+	activeGames.Dec()
+	if len(game.Rounds) > 0 {
+		observePhase(PhaseResults, game.Rounds[len(game.Rounds)-1].ResultsAt)
+	}
+	publish(EventGameFinished, gameId, "", "")
+}
+
+// startVoting ends answering in the round, bots vote right away.
+func startVoting(gameId string, roundId string) {
+	game := games[gameId]
+	for i := range game.Rounds {
+		r := &game.Rounds[i]
+		if r.Id != roundId || !r.VotingStartedAt.IsZero() {
+			continue
+		}
+		// From now on joining players are late
+		game.Started = true
+		r.VotingStartedAt = time.Now()
+		games[gameId] = game
+		observePhase(PhaseAnswering, r.StartedAt)
+		publish(EventVotingStarted, gameId, roundId, "")
+		botsVote(gameId, roundId)
+	}
+}
+
+// finishVoting ends voting in the round and shows its results.
+func finishVoting(gameId string, roundId string) {
+	game := games[gameId]
+	for i := range game.Rounds {
+		r := &game.Rounds[i]
+		if r.Id != roundId || !r.ResultsAt.IsZero() {
+			continue
+		}
+		r.ResultsAt = time.Now()
+		games[gameId] = game
+		observePhase(PhaseVoting, r.VotingStartedAt)
+		publish(EventRoundFinished, gameId, roundId, "")
 	}
-	playerReadyLock.Unlock()
 }
 
 func GetLatestRound(gameId string) (Round, error) {
+	stateLock.RLock()
+	defer stateLock.RUnlock()
+	round, err := latestRound(gameId)
+	return round.clone(), err
+}
+
+func latestRound(gameId string) (Round, error) {
 	game := games[gameId]
 	if len(game.Rounds) == 0 {
 		logMessage := "Error when trying to get latest round. Game has no rounds yet."
@@ -151,6 +319,12 @@ func GetLatestRound(gameId string) (Round, error) {
 }
 
 func AddAnswer(gameId string, playerId string, roundId string, answerText string) error {
+	stateLock.Lock()
+	defer stateLock.Unlock()
+	return addAnswer(gameId, playerId, roundId, answerText)
+}
+
+func addAnswer(gameId string, playerId string, roundId string, answerText string) error {
 	game, ok := games[gameId]
 	if !ok {
 		return errors.New("Game " + gameId + " does not exist")
@@ -166,6 +340,19 @@ func AddAnswer(gameId string, playerId string, roundId string, answerText string
 		if r.Id != roundId {
 			continue
 		}
+		if !game.CanPlay(r, playerId) {
+			return &ValidationError{"The round started before you joined, you play from the next one."}
+		}
+		if game.RoundPhase(r) != PhaseAnswering {
+			return &ValidationError{"Answering is over for this round."}
+		}
+		answerText = NormalizeAnswer(answerText)
+		if err := validateAnswer(game.Rules.Answers, r, playerId, answerText); err != nil {
+			return err
+		}
+		if err := game.GameMode().ValidateAnswer(&game, r, playerId, answerText); err != nil {
+			return err
+		}
 		answer := Answer{
 			Id:     uuid.New().String(),
 			Text:   answerText,
@@ -184,14 +371,30 @@ func AddAnswer(gameId string, playerId string, roundId string, answerText string
 		if !updatedAnswer {
 			game.Rounds[i].Answers = append(game.Rounds[i].Answers, answer)
 		}
+		game.touch()
+		games[gameId] = game
 
 		slog.Debug("Adding answer", "game", game, "player", player, "roundId", r.Id, "answer", answer)
+		answersSubmitted.Inc()
+
+		publish(EventAnswerSubmitted, gameId, roundId, playerId)
+
+		// Bots vote as soon as the last answer comes in
+		if !updatedAnswer && allPlayerAnswered(gameId, roundId) {
+			startVoting(gameId, roundId)
+		}
 		return nil
 	}
 	return errors.New("Could not add answer")
 }
 
 func AddChoice(gameId string, playerId string, roundId string, choiceId string) error {
+	stateLock.Lock()
+	defer stateLock.Unlock()
+	return addChoice(gameId, playerId, roundId, choiceId)
+}
+
+func addChoice(gameId string, playerId string, roundId string, choiceId string) error {
 	game, ok := games[gameId]
 	if !ok {
 		return errors.New("Game " + gameId + " does not exist")
@@ -204,48 +407,133 @@ func AddChoice(gameId string, playerId string, roundId string, choiceId string)
 
 	for i, r := range game.Rounds {
 		if r.Id == roundId {
+			if !game.CanPlay(&r, playerId) {
+				return &ValidationError{"The round started before you joined, you play from the next one."}
+			}
+			if game.RoundPhase(&r) != PhaseVoting {
+				return &ValidationError{"Voting is not open for this round."}
+			}
+			if r.HasVoted(playerId) {
+				return &ValidationError{"You already voted in this round."}
+			}
+			if own, ok := r.AnswerOf(playerId); ok && own.Id == choiceId {
+				return &ValidationError{"You cannot vote for your own answer."}
+			}
 			for j := range r.Answers {
 				a := &r.Answers[j]
 				if a.Id == choiceId {
 					a.Voters = append(a.Voters, player)
-					game.Score[a.Owner.Id] += 1
 					game.Rounds[i].ChoiceCount++
+					game.updateScore()
+					game.touch()
+					games[gameId] = game
 					slog.Debug("Added choice", "game", game, "player", player, "roundId", r.Id, "answer", a)
 					slog.Info("Score update", "score", game.Score)
 					// Setting player ready in order to be able to check when starting next round
 					player.PlayerReady = false
 					players[playerId] = player
+					votesSubmitted.Inc()
+					publish(EventVoteSubmitted, gameId, roundId, playerId)
+					if allPlayersSelectedChoice(gameId, roundId) {
+						finishVoting(gameId, roundId)
+					}
 					return nil
 				}
 			}
 		}
 	}
-	return errors.New("Could not add choice")
+	return &ValidationError{"This answer is not part of the round."}
+}
 
+// GetRoundReveal returns the answers of a round with their authors, voters
+// and the points each one earned, best answers first.
+func GetRoundReveal(gameId string, roundId string) ([]RevealedAnswer, error) {
+	stateLock.RLock()
+	defer stateLock.RUnlock()
+	game, ok := games[gameId]
+	if !ok {
+		return nil, errors.New("Game " + gameId + " does not exist")
+	}
+
+	for i := range game.Rounds {
+		r := &game.Rounds[i]
+		if r.Id != roundId {
+			continue
+		}
+		roundScores := game.GameMode().ComputeScores(&game, r)
+		reveal := []RevealedAnswer{}
+		for _, a := range r.Answers {
+			voterNames := []string{}
+			for _, v := range a.Voters {
+				voterNames = append(voterNames, v.Name)
+			}
+			reveal = append(reveal, RevealedAnswer{
+				Text:       a.Text,
+				OwnerName:  a.Owner.Name,
+				VoterNames: voterNames,
+				Points:     roundScores[a.Owner.Id],
+			})
+		}
+		sort.SliceStable(reveal, func(i, j int) bool { return reveal[i].Points > reveal[j].Points })
+		return reveal, nil
+	}
+	return nil, errors.New("Round " + roundId + " does not exist")
 }
 
 func GetScore(gameId string) map[string]int {
+	stateLock.RLock()
+	defer stateLock.RUnlock()
 	game := games[gameId]
-	return game.Score
+	return maps.Clone(game.Score)
+}
+
+func GetGame(gameId string) (Game, bool) {
+	stateLock.RLock()
+	defer stateLock.RUnlock()
+	game, ok := games[gameId]
+	return game.clone(), ok
+}
+
+// ListGames returns every game, finished ones included.
+func ListGames() []Game {
+	stateLock.RLock()
+	defer stateLock.RUnlock()
+	list := []Game{}
+	for _, game := range games {
+		list = append(list, game.clone())
+	}
+	return list
 }
 
 func GetPlayer(playerId string) Player {
+	stateLock.RLock()
+	defer stateLock.RUnlock()
 	return players[playerId]
 }
 
-func AddPlayerToGame(gameId string, player Player) {
+func addPlayerToGame(gameId string, player Player) {
 	// Adding a player copy so the variables are not carried over to different games
 	playerCopy := player
 	game := games[gameId]
+	// Until the game started everybody who joins plays the current round
+	if n := len(game.Rounds); n > 0 && !game.Started {
+		latest := &game.Rounds[n-1]
+		latest.Participants = append(game.participants(latest), player.Id)
+	}
 	game.Players = append(game.Players, playerCopy)
+	game.touch()
 	games[gameId] = game
 	slog.Info("Player added to game", "player", playerCopy, "game", game)
+	publish(EventPlayerJoined, gameId, "", player.Id)
 }
 
 func CreatePlayer(playerName string) (Player, bool) {
+	stateLock.Lock()
+	defer stateLock.Unlock()
 	playerId := uuid.New().String()
-	player := Player{playerId, playerName, false}
+	player := Player{Id: playerId, Name: playerName, PlayerReady: false, LastSeen: time.Now()}
 	players[playerId] = player
+	activePlayers.Inc()
 	slog.Info("Created player.", "player", player)
 	return player, true
 }
@@ -254,32 +542,164 @@ type Game struct {
 	Id              string
 	Players         []Player
 	Rounds          []Round
-	Password        string
+	PasswordSalt    []byte
+	PasswordHash    []byte
+	RoomKey         string // index of the game in rooms
 	Started         bool
 	IsComplete      bool
 	Score           map[string]int // map[playerId]points
 	NextPlayerIndex int
+	Mode            string
+	Rules           Rules
+	LastActivity    time.Time // the janitor ends games that stay idle for too long
+	FinishedAt      time.Time
 }
 
-func (g *Game) GetNextPlayerName() string {
-	slog.Debug("getting next player name", "index", g.NextPlayerIndex, "players", len(g.Players),
+// LogValue logs a summary of the game, which leaves out the password hash
+// and the answers of the rounds.
+func (g Game) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("id", g.Id),
+		slog.String("mode", g.Mode),
+		slog.Int("players", len(g.Players)),
+		slog.Int("rounds", len(g.Rounds)),
+		slog.Bool("complete", g.IsComplete),
+	)
+}
+
+// clone copies the game down to the voters of every answer, so the copy can
+// be read while the game goes on.
+func (g Game) clone() Game {
+	g.Players = slices.Clone(g.Players)
+	g.Score = maps.Clone(g.Score)
+	rounds := make([]Round, len(g.Rounds))
+	for i := range g.Rounds {
+		rounds[i] = g.Rounds[i].clone()
+	}
+	g.Rounds = rounds
+	return g
+}
+
+// touch records activity so the janitor keeps the game.
+func (g *Game) touch() {
+	g.LastActivity = time.Now()
+}
+
+// GameMode returns the rules this game was created with.
+func (g *Game) GameMode() GameMode {
+	mode, ok := GetGameMode(g.Mode)
+	if !ok {
+		slog.Error("Unknown game mode, falling back to default", "mode", g.Mode)
+		mode, _ = GetGameMode(DefaultGameMode)
+	}
+	return mode
+}
+
+// updateScore recomputes the cumulative score from the votes of every round.
+func (g *Game) updateScore() {
+	mode := g.GameMode()
+	for k := range g.Score {
+		delete(g.Score, k)
+	}
+	for i := range g.Rounds {
+		for playerId, points := range mode.ComputeScores(g, &g.Rounds[i]) {
+			g.Score[playerId] += points
+		}
+	}
+}
+
+const (
+	PhaseAnswering string = "answering"
+	PhaseVoting    string = "voting"
+	PhaseResults   string = "results"
+	PhaseFinished  string = "finished"
+)
+
+// RoundPhase tells what the players are currently doing in the round.
+func (g *Game) RoundPhase(r *Round) string {
+	switch {
+	case !g.allAnswered(r):
+		return PhaseAnswering
+	case !g.allVoted(r):
+		return PhaseVoting
+	case g.IsComplete && len(g.Rounds) > 0 && g.Rounds[len(g.Rounds)-1].Id == r.Id:
+		return PhaseFinished
+	default:
+		return PhaseResults
+	}
+}
+
+// GetNextPlayer returns the player the next question is about, taking turns.
+func (g *Game) GetNextPlayer() Player {
+	slog.Debug("getting next player", "index", g.NextPlayerIndex, "players", len(g.Players),
 		"modulo", g.NextPlayerIndex%len(g.Players))
 	index := g.NextPlayerIndex % len(g.Players)
 	g.NextPlayerIndex++
-	return g.Players[index].Name
+	return g.Players[index]
 }
 
 type Player struct {
 	Id          string
 	Name        string
 	PlayerReady bool
+	IsBot       bool
+	BotStrategy string // one of the BotVote* strategies, only set for bots
+	LastSeen    time.Time
+	Away        bool // not seen for longer than the grace period, rounds do not wait for them
 }
 
 type Round struct {
 	Id          string
 	Question    string
+	SubjectId   string // the player the question is about
 	Answers     []Answer
 	ChoiceCount int
+	// The players the round waits for, players who joined late are missing
+	Participants []string
+	// When the round entered each phase, for the phase duration metrics
+	StartedAt       time.Time
+	VotingStartedAt time.Time
+	ResultsAt       time.Time
+}
+
+func (r Round) clone() Round {
+	r.Participants = slices.Clone(r.Participants)
+	answers := make([]Answer, len(r.Answers))
+	for i, a := range r.Answers {
+		a.Voters = slices.Clone(a.Voters)
+		answers[i] = a
+	}
+	r.Answers = answers
+	return r
+}
+
+// AnswerOf returns the answer the player gave in this round.
+func (r *Round) AnswerOf(playerId string) (Answer, bool) {
+	for _, a := range r.Answers {
+		if a.Owner.Id == playerId {
+			return a, true
+		}
+	}
+	return Answer{}, false
+}
+
+// HasVoted reports whether the player already picked an answer in this round.
+func (r *Round) HasVoted(playerId string) bool {
+	for _, a := range r.Answers {
+		for _, v := range a.Voters {
+			if v.Id == playerId {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type RevealedAnswer struct {
+	Text       string
+	OwnerName  string
+	VoterNames []string
+	Points     int
 }
 
 type Answer struct {
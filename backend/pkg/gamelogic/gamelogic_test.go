@@ -0,0 +1,193 @@
+package gamelogic
+
+import (
+	"errors"
+	"testing"
+)
+
+// resetState starts the test with no games, players or history, and puts
+// everything back afterwards.
+func resetState(t *testing.T) {
+	t.Helper()
+	savedGames, savedPlayers, savedRooms, savedHistory := games, players, rooms, history
+	games = make(map[string]Game)
+	players = make(map[string]Player)
+	rooms = make(map[string]string)
+	history = make(map[string]ArchivedGame)
+	t.Cleanup(func() {
+		games, players, rooms, history = savedGames, savedPlayers, savedRooms, savedHistory
+	})
+}
+
+// testConfig is the default config with a room secret, as the server hands
+// it to the handlers.
+func testConfig() Config {
+	config := DefaultConfig()
+	config.RoomSecret = "the secret of the tests"
+	return config
+}
+
+// newTestGame creates a game of the mode with a player for each name, the
+// first one creating it.
+func newTestGame(t *testing.T, config Config, password string, mode string, names ...string) (Game, []Player) {
+	t.Helper()
+	created := []Player{}
+	for _, name := range names {
+		p, _ := CreatePlayer(name)
+		created = append(created, p)
+	}
+	game, ok := CreateGame(config, password, created[0].Id, mode)
+	if !ok {
+		t.Fatalf("could not create game %q", password)
+	}
+	for _, p := range created[1:] {
+		if _, err := JoinGame(config, password, p.Id); err != nil {
+			t.Fatalf("%s could not join: %v", p.Name, err)
+		}
+	}
+	game, _ = GetGame(game.Id)
+	return game, created
+}
+
+// playRound answers and votes the latest round until its phase is reached,
+// everybody votes for the answer of the next player.
+func playRound(t *testing.T, gameId string, created []Player, phase string) {
+	t.Helper()
+	round, _ := GetLatestRound(gameId)
+	if phase == PhaseAnswering {
+		return
+	}
+	for i, p := range created {
+		if err := AddAnswer(gameId, p.Id, round.Id, []string{"tacos", "sushi", "ramen"}[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if phase == PhaseVoting {
+		return
+	}
+	round, _ = GetLatestRound(gameId)
+	for i, p := range created {
+		owner := created[(i+1)%len(created)].Id
+		for _, a := range round.Answers {
+			if a.Owner.Id != owner {
+				continue
+			}
+			if err := AddChoice(gameId, p.Id, round.Id, a.Id); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestEndGame(t *testing.T) {
+	tests := []struct {
+		name   string
+		phase  string
+		player int // index of the player ending the game, -1 for a stranger
+		err    error
+		ended  bool
+	}{
+		{name: "while answering", phase: PhaseAnswering, err: ErrRoundInProgress},
+		{name: "while voting", phase: PhaseVoting, err: ErrRoundInProgress},
+		{name: "after the results", phase: PhaseResults, ended: true},
+		{name: "by another player", phase: PhaseResults, player: 2, ended: true},
+		{name: "by a stranger", phase: PhaseResults, player: -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetState(t)
+			game, created := newTestGame(t, testConfig(), "pizza", DefaultGameMode, "Ann", "Bob", "Cat")
+			playRound(t, game.Id, created, tt.phase)
+			playerId := "stranger"
+			if tt.player >= 0 {
+				playerId = created[tt.player].Id
+			}
+
+			err := EndGame(game.Id, playerId)
+			switch {
+			case tt.err != nil && err != tt.err:
+				t.Fatalf("EndGame() = %v, want %v", err, tt.err)
+			case tt.ended && err != nil:
+				t.Fatalf("EndGame() = %v", err)
+			case !tt.ended && err == nil:
+				t.Fatalf("EndGame() = nil, want an error")
+			}
+
+			game, _ = GetGame(game.Id)
+			_, archived := GetArchivedGame(game.Id)
+			_, running := rooms[game.RoomKey]
+			if game.IsComplete != tt.ended || archived != tt.ended || running == tt.ended {
+				t.Errorf("complete %v, archived %v, room taken %v, want the game ended %v",
+					game.IsComplete, archived, running, tt.ended)
+			}
+			if tt.ended {
+				if err := EndGame(game.Id, playerId); err != nil {
+					t.Errorf("ending the game again: %v", err)
+				}
+				if len(HistoryOf(playerId)) != 1 {
+					t.Errorf("the game is in the history %d times", len(HistoryOf(playerId)))
+				}
+			}
+		})
+	}
+}
+
+func TestAddChoice(t *testing.T) {
+	tests := []struct {
+		name  string
+		voter int // index of the voting player
+		owner int // whose answer they pick, -1 for an answer not in the round
+		voted bool
+		want  string // the validation error, empty when the vote counts
+	}{
+		{name: "the answer of another player", voter: 0, owner: 1},
+		{name: "their own answer", voter: 0, owner: 0, want: "You cannot vote for your own answer."},
+		{name: "an answer not in the round", voter: 0, owner: -1, want: "This answer is not part of the round."},
+		{name: "a second vote", voter: 0, owner: 2, voted: true, want: "You already voted in this round."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetState(t)
+			game, created := newTestGame(t, testConfig(), "pizza", DefaultGameMode, "Ann", "Bob", "Cat")
+			playRound(t, game.Id, created, PhaseVoting)
+			round, _ := GetLatestRound(game.Id)
+			choiceOf := func(owner int) string {
+				if answer, ok := round.AnswerOf(created[owner].Id); ok {
+					return answer.Id
+				}
+				t.Fatalf("%s has no answer", created[owner].Name)
+				return ""
+			}
+			if tt.voted {
+				if err := AddChoice(game.Id, created[tt.voter].Id, round.Id, choiceOf(1)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			choiceId := "not-an-answer"
+			if tt.owner >= 0 {
+				choiceId = choiceOf(tt.owner)
+			}
+
+			err := AddChoice(game.Id, created[tt.voter].Id, round.Id, choiceId)
+			var validationErr *ValidationError
+			switch {
+			case tt.want == "" && err != nil:
+				t.Fatalf("AddChoice() = %v", err)
+			case tt.want != "" && (!errors.As(err, &validationErr) || validationErr.Message != tt.want):
+				t.Fatalf("AddChoice() = %v, want %q", err, tt.want)
+			}
+			round, _ = GetLatestRound(game.Id)
+			votes := 0
+			for _, a := range round.Answers {
+				votes += len(a.Voters)
+			}
+			wantVotes := 0
+			if tt.want == "" || tt.voted {
+				wantVotes = 1
+			}
+			if votes != wantVotes {
+				t.Errorf("the round has %d votes, want %d", votes, wantVotes)
+			}
+		})
+	}
+}
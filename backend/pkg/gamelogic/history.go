@@ -0,0 +1,141 @@
+package gamelogic
+
+import (
+	"log/slog"
+	"sort"
+	"time"
+)
+
+// ArchivedGame is a finished game as the history keeps it: every round that
+// was played to the end with its answers, authors and votes, the final
+// standings and the awards. It outlives the game, which the janitor deletes
+// soon after it ended.
+type ArchivedGame struct {
+	Id          string
+	Mode        string
+	StartedAt   time.Time
+	FinishedAt  time.Time
+	Players     []ArchivedPlayer
+	Rounds      []ArchivedRound
+	Leaderboard Leaderboard // after the last round
+	Awards      []Award
+}
+
+type ArchivedPlayer struct {
+	Id    string
+	Name  string
+	IsBot bool
+}
+
+type ArchivedRound struct {
+	Question  string
+	SubjectId string           // the player the question was about
+	Answers   []ArchivedAnswer // best answers first
+}
+
+type ArchivedAnswer struct {
+	Id         string
+	Text       string
+	AuthorId   string
+	AuthorName string
+	VoterIds   []string
+	VoterNames []string
+	Points     int
+}
+
+// history holds the archived games by game id. An archived game never
+// changes, so it is handed out without copying.
+var history map[string]ArchivedGame = make(map[string]ArchivedGame)
+
+// archiveGame adds a game that just ended to the history. Games that ended
+// before any round was voted on leave nothing worth replaying.
+func archiveGame(game Game) {
+	archived := ArchivedGame{
+		Id:          game.Id,
+		Mode:        game.Mode,
+		FinishedAt:  game.FinishedAt,
+		Players:     []ArchivedPlayer{},
+		Rounds:      []ArchivedRound{},
+		Leaderboard: Leaderboard{},
+		Awards:      []Award{},
+	}
+	for _, p := range game.Players {
+		archived.Players = append(archived.Players, ArchivedPlayer{p.Id, p.Name, p.IsBot})
+	}
+
+	mode := game.GameMode()
+	lastPlayed := -1
+	for i := range game.Rounds {
+		r := &game.Rounds[i]
+		if phase := game.RoundPhase(r); phase != PhaseResults && phase != PhaseFinished {
+			continue
+		}
+		if lastPlayed == -1 {
+			archived.StartedAt = r.StartedAt
+		}
+		lastPlayed = i
+
+		roundScores := mode.ComputeScores(&game, r)
+		round := ArchivedRound{Question: r.Question, SubjectId: r.SubjectId, Answers: []ArchivedAnswer{}}
+		for _, a := range r.Answers {
+			answer := ArchivedAnswer{
+				Id:         a.Id,
+				Text:       a.Text,
+				AuthorId:   a.Owner.Id,
+				AuthorName: a.Owner.Name,
+				VoterIds:   []string{},
+				VoterNames: []string{},
+				Points:     roundScores[a.Owner.Id],
+			}
+			for _, v := range a.Voters {
+				answer.VoterIds = append(answer.VoterIds, v.Id)
+				answer.VoterNames = append(answer.VoterNames, v.Name)
+			}
+			round.Answers = append(round.Answers, answer)
+		}
+		sort.SliceStable(round.Answers, func(i, j int) bool { return round.Answers[i].Points > round.Answers[j].Points })
+		archived.Rounds = append(archived.Rounds, round)
+	}
+	if lastPlayed == -1 {
+		slog.Debug("Game has no finished round to archive", "gameId", game.Id)
+		return
+	}
+	archived.Leaderboard = game.leaderboard(lastPlayed)
+	archived.Awards = computeAwards(&archived)
+	history[game.Id] = archived
+	slog.Info("Archived game", "gameId", game.Id, "rounds", len(archived.Rounds))
+}
+
+// GetArchivedGame returns a game of the history.
+func GetArchivedGame(gameId string) (ArchivedGame, bool) {
+	stateLock.RLock()
+	defer stateLock.RUnlock()
+	archived, ok := history[gameId]
+	return archived, ok
+}
+
+// HistoryOf returns the archived games the player took part in, the most
+// recent first.
+func HistoryOf(playerId string) []ArchivedGame {
+	stateLock.RLock()
+	defer stateLock.RUnlock()
+	list := []ArchivedGame{}
+	for _, archived := range history {
+		if archived.HasPlayer(playerId) {
+			list = append(list, archived)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].FinishedAt.After(list[j].FinishedAt) })
+	return list
+}
+
+// HasPlayer reports whether the player took part in the game, only they get
+// to see its history.
+func (a *ArchivedGame) HasPlayer(playerId string) bool {
+	for _, p := range a.Players {
+		if p.Id == playerId {
+			return true
+		}
+	}
+	return false
+}
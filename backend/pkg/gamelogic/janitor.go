@@ -0,0 +1,104 @@
+package gamelogic
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// ExpiryRules decide when the janitor cleans up games and players.
+type ExpiryRules struct {
+	Interval        time.Duration // how often the janitor runs, 0 disables it
+	IdleGameTTL     time.Duration // running games without any activity for this long are ended
+	FinishedGameTTL time.Duration // finished games are deleted this long after they ended
+	PlayerTTL       time.Duration // players in no game are deleted this long after they were last seen
+	HistoryTTL      time.Duration // archived games are deleted this long after they ended
+}
+
+// RunJanitor expires idle games and orphan players by the rules every
+// Interval until ctx is cancelled.
+func RunJanitor(ctx context.Context, rules ExpiryRules) {
+	if rules.Interval <= 0 {
+		slog.Info("Janitor is disabled, games and players are never deleted")
+		return
+	}
+	ticker := time.NewTicker(rules.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			sweep(now, rules)
+		}
+	}
+}
+
+// sweep ends the games that have been idle for too long, which frees their
+// passwords, deletes the finished games past their TTL, then the players
+// that are left without a game and the archived games past theirs.
+func sweep(now time.Time, rules ExpiryRules) {
+	stateLock.Lock()
+	defer stateLock.Unlock()
+	expired, deleted, deletedPlayers := 0, 0, 0
+	attached := map[string]bool{}
+	for gameId, game := range games {
+		if !game.IsComplete && now.Sub(game.LastActivity) > rules.IdleGameTTL {
+			game.IsComplete = true
+			game.FinishedAt = now
+			games[gameId] = game
+			releaseRoom(game)
+			archiveGame(game)
+			activeGames.Dec()
+			expired++
+			slog.Info("Ended idle game", "gameId", gameId, "lastActivity", game.LastActivity)
+			publish(EventGameExpired, gameId, "", "")
+		} else if game.IsComplete && now.Sub(game.FinishedAt) > rules.FinishedGameTTL {
+			delete(games, gameId)
+			closeSubscribers(gameId)
+			deleted++
+			slog.Info("Deleted finished game", "gameId", gameId, "finishedAt", game.FinishedAt)
+			continue
+		}
+		for _, p := range game.Players {
+			attached[p.Id] = true
+		}
+	}
+
+	for playerId, player := range players {
+		if !attached[playerId] && now.Sub(player.LastSeen) > rules.PlayerTTL {
+			delete(players, playerId)
+			activePlayers.Dec()
+			deletedPlayers++
+		}
+	}
+	deletedHistory := 0
+	for gameId, archived := range history {
+		if now.Sub(archived.FinishedAt) > rules.HistoryTTL {
+			delete(history, gameId)
+			deletedHistory++
+		}
+	}
+	slog.Debug("Janitor finished", "expiredGames", expired, "deletedGames", deleted, "deletedPlayers", deletedPlayers,
+		"deletedHistory", deletedHistory)
+}
+
+// backfillTimestamps starts the clocks of games and players loaded from a
+// state file that was saved before they were tracked.
+func backfillTimestamps(now time.Time) {
+	for gameId, game := range games {
+		if game.LastActivity.IsZero() {
+			game.LastActivity = now
+		}
+		if game.IsComplete && game.FinishedAt.IsZero() {
+			game.FinishedAt = now
+		}
+		games[gameId] = game
+	}
+	for playerId, player := range players {
+		if player.LastSeen.IsZero() {
+			player.LastSeen = now
+			players[playerId] = player
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package gamelogic
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSweep(t *testing.T) {
+	resetState(t)
+	rules := testConfig().Expiry
+	now := time.Now()
+	idle, _ := newTestGame(t, testConfig(), "idle", DefaultGameMode, "Ann", "Bob")
+	busy, _ := newTestGame(t, testConfig(), "busy", DefaultGameMode, "Cat", "Dan")
+	orphan, _ := CreatePlayer("Eve")
+	lurker, _ := CreatePlayer("Fay")
+
+	game := games[idle.Id]
+	game.LastActivity = now.Add(-2 * rules.IdleGameTTL)
+	games[idle.Id] = game
+	player := players[orphan.Id]
+	player.LastSeen = now.Add(-2 * rules.PlayerTTL)
+	players[orphan.Id] = player
+
+	sweep(now, rules)
+
+	tests := []struct {
+		name string
+		got  bool
+		want bool
+	}{
+		{"idle game is complete", games[idle.Id].IsComplete, true},
+		{"busy game keeps running", games[busy.Id].IsComplete, false},
+		{"room of the idle game is free", rooms[idle.RoomKey] == idle.Id, false},
+		{"room of the busy game is kept", rooms[busy.RoomKey] == busy.Id, true},
+		{"orphan player is deleted", players[orphan.Id].Id != "", false},
+		{"recently seen player is kept", players[lurker.Id].Id != "", true},
+		{"players of the idle game are kept", players[idle.Players[0].Id].Id != "", true},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+	if _, ok := CreateGame(testConfig(), "idle", lurker.Id, DefaultGameMode); !ok {
+		t.Errorf("password of the idle game is still taken")
+	}
+
+	// Once finished for longer than the TTL the game is gone, and so are its
+	// players who have not been seen since
+	later := now.Add(2 * rules.FinishedGameTTL)
+	for _, p := range idle.Players {
+		player := players[p.Id]
+		player.LastSeen = now
+		players[p.Id] = player
+	}
+	sweep(later, rules)
+	if _, ok := games[idle.Id]; ok {
+		t.Errorf("finished game is not deleted after its TTL")
+	}
+	for _, p := range idle.Players {
+		if _, ok := players[p.Id]; ok {
+			t.Errorf("player %s of the deleted game is kept", p.Name)
+		}
+	}
+}
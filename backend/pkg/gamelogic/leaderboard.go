@@ -0,0 +1,115 @@
+package gamelogic
+
+import (
+	"errors"
+	"sort"
+)
+
+type LeaderboardEntry struct {
+	PlayerId   string
+	PlayerName string
+	Points     int
+	Rank       int // dense rank, players with equal points share a rank
+	Delta      int // points earned in the round the leaderboard was built for
+	RankChange int // positive when the player moved up compared to the previous round
+}
+
+// RankSteps is how many places the player moved, up or down.
+func (e LeaderboardEntry) RankSteps() int {
+	if e.RankChange < 0 {
+		return -e.RankChange
+	}
+	return e.RankChange
+}
+
+type Leaderboard []LeaderboardEntry
+
+// GetLeaderboard returns the standings of every player in the game right after
+// the given round, including players that never got a vote.
+func GetLeaderboard(gameId string, roundId string) (Leaderboard, error) {
+	stateLock.RLock()
+	defer stateLock.RUnlock()
+	game, ok := games[gameId]
+	if !ok {
+		return nil, errors.New("Game " + gameId + " does not exist")
+	}
+
+	roundIndex := -1
+	for i, r := range game.Rounds {
+		if r.Id == roundId {
+			roundIndex = i
+		}
+	}
+	if roundIndex == -1 {
+		return nil, errors.New("Round " + roundId + " does not exist")
+	}
+
+	return game.leaderboard(roundIndex), nil
+}
+
+// leaderboard builds the standings including every round up to roundIndex.
+func (g *Game) leaderboard(roundIndex int) Leaderboard {
+	mode := g.GameMode()
+	before := make(map[string]int)
+	after := make(map[string]int)
+	for i := 0; i <= roundIndex && i < len(g.Rounds); i++ {
+		for playerId, points := range mode.ComputeScores(g, &g.Rounds[i]) {
+			after[playerId] += points
+			if i < roundIndex {
+				before[playerId] += points
+			}
+		}
+	}
+
+	previousRanks := rankPlayers(g.Players, before)
+	board := Leaderboard{}
+	for _, p := range g.Players {
+		board = append(board, LeaderboardEntry{
+			PlayerId:   p.Id,
+			PlayerName: p.Name,
+			Points:     after[p.Id],
+			Delta:      after[p.Id] - before[p.Id],
+		})
+	}
+	board.sortAndRank()
+	for i := range board {
+		board[i].RankChange = previousRanks[board[i].PlayerId] - board[i].Rank
+	}
+	return board
+}
+
+// sortAndRank orders the entries by points, then by name so the order is
+// stable between refreshes, and assigns dense ranks.
+func (b Leaderboard) sortAndRank() {
+	sort.SliceStable(b, func(i, j int) bool {
+		if b[i].Points != b[j].Points {
+			return b[i].Points > b[j].Points
+		}
+		if b[i].PlayerName != b[j].PlayerName {
+			return b[i].PlayerName < b[j].PlayerName
+		}
+		return b[i].PlayerId < b[j].PlayerId
+	})
+
+	rank := 0
+	for i := range b {
+		if i == 0 || b[i].Points != b[i-1].Points {
+			rank++
+		}
+		b[i].Rank = rank
+	}
+}
+
+func rankPlayers(players []Player, points map[string]int) map[string]int {
+	board := Leaderboard{}
+	for _, p := range players {
+		board = append(board, LeaderboardEntry{PlayerId: p.Id, PlayerName: p.Name, Points: points[p.Id]})
+	}
+	board.sortAndRank()
+
+	ranks := make(map[string]int)
+	for _, e := range board {
+		ranks[e.PlayerId] = e.Rank
+	}
+	return ranks
+}
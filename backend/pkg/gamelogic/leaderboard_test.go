@@ -0,0 +1,84 @@
+package gamelogic
+
+import "testing"
+
+func TestLeaderboard(t *testing.T) {
+	ann := Player{Id: "ann", Name: "Ann"}
+	bob := Player{Id: "bob", Name: "Bob"}
+	cat := Player{Id: "cat", Name: "Cat"}
+	// answered builds a round in which each player got the given votes
+	answered := func(votes map[string]int) Round {
+		r := Round{Id: "r"}
+		for _, p := range []Player{ann, bob, cat} {
+			a := Answer{Id: p.Id, Owner: p}
+			for i := 0; i < votes[p.Id]; i++ {
+				a.Voters = append(a.Voters, Player{Id: "voter"})
+			}
+			r.Answers = append(r.Answers, a)
+		}
+		return r
+	}
+	game := Game{
+		Players: []Player{ann, bob, cat},
+		Mode:    DefaultGameMode,
+		Rounds: []Round{
+			answered(map[string]int{"ann": 2}),
+			answered(map[string]int{"bob": 1, "cat": 3}),
+		},
+	}
+
+	tests := []struct {
+		name       string
+		roundIndex int
+		want       Leaderboard
+	}{
+		{
+			name:       "first round, ties share a rank",
+			roundIndex: 0,
+			want: Leaderboard{
+				{PlayerId: "ann", PlayerName: "Ann", Points: 2, Rank: 1, Delta: 2, RankChange: 0},
+				{PlayerId: "bob", PlayerName: "Bob", Points: 0, Rank: 2, Delta: 0, RankChange: -1},
+				{PlayerId: "cat", PlayerName: "Cat", Points: 0, Rank: 2, Delta: 0, RankChange: -1},
+			},
+		},
+		{
+			name:       "second round, cat overtakes ann",
+			roundIndex: 1,
+			want: Leaderboard{
+				{PlayerId: "cat", PlayerName: "Cat", Points: 3, Rank: 1, Delta: 3, RankChange: 1},
+				{PlayerId: "ann", PlayerName: "Ann", Points: 2, Rank: 2, Delta: 0, RankChange: -1},
+				{PlayerId: "bob", PlayerName: "Bob", Points: 1, Rank: 3, Delta: 1, RankChange: -1},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := game.leaderboard(tt.roundIndex)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d entries, want %d", len(got), len(tt.want))
+			}
+			for i := range tt.want {
+				if got[i] != tt.want[i] {
+					t.Errorf("entry %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRankSteps(t *testing.T) {
+	tests := []struct {
+		rankChange int
+		want       int
+	}{
+		{rankChange: 2, want: 2},
+		{rankChange: -2, want: 2},
+		{rankChange: 0, want: 0},
+	}
+	for _, tt := range tests {
+		e := LeaderboardEntry{RankChange: tt.rankChange}
+		if got := e.RankSteps(); got != tt.want {
+			t.Errorf("RankSteps() with RankChange %d = %d, want %d", tt.rankChange, got, tt.want)
+		}
+	}
+}
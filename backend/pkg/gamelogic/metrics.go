@@ -0,0 +1,68 @@
+package gamelogic
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// The metrics are updated where the game state changes, so they are exact
+// without scanning the games on every scrape.
+var (
+	activeGames = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "partygame_games_active",
+		Help: "Games that are not complete yet.",
+	})
+	activePlayers = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "partygame_players",
+		Help: "Players known to the server.",
+	})
+	eventStreams = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "partygame_event_streams",
+		Help: "Connected clients listening for game events.",
+	})
+	gamesCreated = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "partygame_games_created_total",
+		Help: "Games created.",
+	})
+	roundsCreated = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "partygame_rounds_created_total",
+		Help: "Rounds created.",
+	})
+	answersSubmitted = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "partygame_answers_total",
+		Help: "Answers accepted, including changed answers.",
+	})
+	votesSubmitted = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "partygame_votes_total",
+		Help: "Votes accepted.",
+	})
+	phaseDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "partygame_phase_duration_seconds",
+		Help:    "How long the rounds stay in each phase.",
+		Buckets: []float64{5, 10, 20, 30, 45, 60, 90, 120, 180, 300, 600},
+	}, []string{"phase"})
+)
+
+// observePhase records a phase that started at started and ends now. Rounds
+// loaded from an older state file have no timestamps and are skipped.
+func observePhase(phase string, started time.Time) {
+	if started.IsZero() {
+		return
+	}
+	phaseDuration.WithLabelValues(phase).Observe(time.Since(started).Seconds())
+}
+
+// resetGauges sets the gauges from the current games and players. It is
+// only called while nothing else touches the state, after loading it.
+func resetGauges() {
+	active := 0
+	for _, g := range games {
+		if !g.IsComplete {
+			active++
+		}
+	}
+	activeGames.Set(float64(active))
+	activePlayers.Set(float64(len(players)))
+}
@@ -0,0 +1,121 @@
+package gamelogic
+
+import (
+	"log/slog"
+	"sort"
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+const DefaultGameMode string = "classic"
+
+// GameMode holds the rules of a game variant. The handlers and the rest of
+// gamelogic only talk to the mode through this interface, so new variants
+// can be added by implementing it and calling RegisterGameMode.
+type GameMode interface {
+	Name() string
+	Description() string
+	// Setup is called once when the game is created, before the first round.
+	Setup(game *Game)
+	// NextRound builds the round that will be appended to the game.
+	NextRound(game *Game) Round
+	// ValidateAnswer returns an error if the answer is not allowed.
+	ValidateAnswer(game *Game, round *Round, playerId string, answerText string) error
+	// ComputeScores returns the points earned in a single round, keyed by player id.
+	ComputeScores(game *Game, round *Round) map[string]int
+	// IsFinished reports whether no more rounds should be played.
+	IsFinished(game *Game) bool
+}
+
+var gameModes map[string]GameMode = make(map[string]GameMode)
+
+func init() {
+	RegisterGameMode(classicMode{})
+	RegisterGameMode(sprintMode{rounds: 5})
+}
+
+func RegisterGameMode(mode GameMode) {
+	gameModes[mode.Name()] = mode
+}
+
+// GetGameMode returns the registered mode with the given name. An empty name
+// returns the default mode.
+func GetGameMode(name string) (GameMode, bool) {
+	if name == "" {
+		name = DefaultGameMode
+	}
+	mode, ok := gameModes[name]
+	return mode, ok
+}
+
+// GameModes returns all registered modes sorted by name.
+func GameModes() []GameMode {
+	modes := []GameMode{}
+	for _, m := range gameModes {
+		modes = append(modes, m)
+	}
+	sort.Slice(modes, func(i, j int) bool { return modes[i].Name() < modes[j].Name() })
+	return modes
+}
+
+// classicMode is the original flow: endless rounds, one point per vote.
+type classicMode struct{}
+
+func (classicMode) Name() string {
+	return "classic"
+}
+
+func (classicMode) Description() string {
+	return "Classic (play until you stop)"
+}
+
+func (classicMode) Setup(game *Game) {
+	game.Score = make(map[string]int)
+}
+
+func (classicMode) NextRound(game *Game) Round {
+	round := Round{}
+	round.Id = uuid.New().String()
+	subject := game.GetNextPlayer()
+	round.SubjectId = subject.Id
+	round.Question = GetRandomQuestion(subject.Name)
+	round.Answers = []Answer{}
+	return round
+}
+
+func (classicMode) ValidateAnswer(game *Game, round *Round, playerId string, answerText string) error {
+	return nil
+}
+
+func (classicMode) ComputeScores(game *Game, round *Round) map[string]int {
+	scores := make(map[string]int)
+	for _, a := range round.Answers {
+		scores[a.Owner.Id] += len(a.Voters)
+	}
+	return scores
+}
+
+// IsFinished is never true, classic games go on until a player ends them.
+func (classicMode) IsFinished(game *Game) bool {
+	return false
+}
+
+// sprintMode plays the classic rules for a fixed number of rounds.
+type sprintMode struct {
+	classicMode
+	rounds int
+}
+
+func (sprintMode) Name() string {
+	return "sprint"
+}
+
+func (m sprintMode) Description() string {
+	return "Sprint (" + strconv.Itoa(m.rounds) + " rounds)"
+}
+
+func (m sprintMode) IsFinished(game *Game) bool {
+	slog.Debug("Checking sprint finished", "rounds", len(game.Rounds), "limit", m.rounds)
+	return len(game.Rounds) >= m.rounds
+}
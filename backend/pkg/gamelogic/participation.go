@@ -0,0 +1,121 @@
+package gamelogic
+
+import (
+	"errors"
+	"time"
+)
+
+// What happens to players joining a game that already started, i.e. after
+// the first round reached voting.
+const (
+	LateJoinReject    string = "reject"    // joining fails
+	LateJoinSpectate  string = "spectate"  // they watch the current round and play from the next one
+	LateJoinImmediate string = "immediate" // they may play the current round, but it does not wait for them
+)
+
+var ErrGameStarted = errors.New("Game already started.")
+
+type PlayerRules struct {
+	MinPlayers int // rounds do not leave answering before this many players took part
+	MaxPlayers int // caps every game, bots included
+	LateJoin   string
+	// Players not seen for this long are away and the rounds stop waiting
+	// for them, 0 disables it
+	AwayAfter time.Duration
+}
+
+// IsLateJoinPolicy reports whether policy is one of the LateJoin* values.
+func IsLateJoinPolicy(policy string) bool {
+	return policy == LateJoinReject || policy == LateJoinSpectate || policy == LateJoinImmediate
+}
+
+// participants returns the players the round waits for. Rounds saved before
+// rounds tracked them wait for everybody.
+func (g *Game) participants(r *Round) []string {
+	if r.Participants != nil {
+		return r.Participants
+	}
+	ids := []string{}
+	for _, p := range g.Players {
+		ids = append(ids, p.Id)
+	}
+	return ids
+}
+
+// IsParticipant reports whether the round waits for the player.
+func (g *Game) IsParticipant(r *Round, playerId string) bool {
+	for _, id := range g.participants(r) {
+		if id == playerId {
+			return true
+		}
+	}
+	return false
+}
+
+// CanPlay reports whether the player may answer and vote in the round.
+func (g *Game) CanPlay(r *Round, playerId string) bool {
+	if g.IsParticipant(r, playerId) {
+		return true
+	}
+	if g.Rules.Players.LateJoin != LateJoinImmediate {
+		return false
+	}
+	for _, p := range g.Players {
+		if p.Id == playerId {
+			return true
+		}
+	}
+	return false
+}
+
+// isAway reports whether the rounds of the game stop waiting for the player.
+// Players that are not in the game are gone.
+func (g *Game) isAway(playerId string) bool {
+	for _, p := range g.Players {
+		if p.Id == playerId {
+			return p.Away
+		}
+	}
+	return true
+}
+
+// allAnswered reports whether answering in the round is over. It waits for
+// the participants who are not away, and once voting started a player coming
+// back does not reopen it.
+func (g *Game) allAnswered(r *Round) bool {
+	if !r.VotingStartedAt.IsZero() {
+		return true
+	}
+	answered := 0
+	for _, id := range g.participants(r) {
+		if _, ok := r.AnswerOf(id); ok {
+			answered++
+		} else if !g.isAway(id) {
+			return false
+		}
+	}
+	return answered >= g.Rules.Players.MinPlayers
+}
+
+func (g *Game) allVoted(r *Round) bool {
+	if !r.ResultsAt.IsZero() {
+		return true
+	}
+	for _, id := range g.participants(r) {
+		if !r.HasVoted(id) && !g.isAway(id) {
+			return false
+		}
+	}
+	return true
+}
+
+// allReady reports whether every player the round waited for, and who is
+// still there, is ready for the next one.
+func (g *Game) allReady(r *Round) bool {
+	for _, p := range g.Players {
+		if !p.PlayerReady && g.IsParticipant(r, p.Id) && !p.Away {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,137 @@
+package gamelogic
+
+import "testing"
+
+func TestParticipants(t *testing.T) {
+	ann := Player{Id: "ann"}
+	bob := Player{Id: "bob"}
+	game := Game{Players: []Player{ann, bob}}
+	tests := []struct {
+		name  string
+		round Round
+		want  []string
+	}{
+		{name: "tracked participants", round: Round{Participants: []string{"ann"}}, want: []string{"ann"}},
+		{name: "nobody", round: Round{Participants: []string{}}, want: []string{}},
+		{name: "saved before they were tracked", round: Round{}, want: []string{"ann", "bob"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := game.participants(&tt.round)
+			if len(got) != len(tt.want) {
+				t.Fatalf("participants() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("participants() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// TestLateJoin starts a game, so the round is voting, lets a third player
+// join and checks what they may do under each policy.
+func TestLateJoin(t *testing.T) {
+	tests := []struct {
+		policy      string
+		joinErr     error
+		participant bool // the current round waits for them
+		canPlay     bool
+	}{
+		{policy: LateJoinReject, joinErr: ErrGameStarted},
+		{policy: LateJoinSpectate, participant: false, canPlay: false},
+		{policy: LateJoinImmediate, participant: false, canPlay: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.policy, func(t *testing.T) {
+			resetState(t)
+			config := testConfig()
+			config.Players.LateJoin = tt.policy
+			game, founders := newTestGame(t, config, "pizza", DefaultGameMode, "Ann", "Bob")
+			round := game.Rounds[0]
+			for i, p := range founders {
+				if err := AddAnswer(game.Id, p.Id, round.Id, []string{"tacos", "sushi"}[i]); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			late, _ := CreatePlayer("Cat")
+			_, err := JoinGame(config, "pizza", late.Id)
+			if err != tt.joinErr {
+				t.Fatalf("JoinGame() = %v, want %v", err, tt.joinErr)
+			}
+			if err != nil {
+				return
+			}
+			game, _ = GetGame(game.Id)
+			round = game.Rounds[0]
+			if got := game.IsParticipant(&round, late.Id); got != tt.participant {
+				t.Errorf("IsParticipant() = %v, want %v", got, tt.participant)
+			}
+			if got := game.CanPlay(&round, late.Id); got != tt.canPlay {
+				t.Errorf("CanPlay() = %v, want %v", got, tt.canPlay)
+			}
+			for _, p := range founders {
+				if !game.CanPlay(&round, p.Id) {
+					t.Errorf("%s cannot play the round they started", p.Name)
+				}
+			}
+			if game.CanPlay(&round, "stranger") {
+				t.Errorf("a player outside the game can play")
+			}
+		})
+	}
+}
+
+// TestJoinBeforeStart lets a player join while the first round is still
+// answering, under every policy they play it.
+func TestJoinBeforeStart(t *testing.T) {
+	for _, policy := range []string{LateJoinReject, LateJoinSpectate, LateJoinImmediate} {
+		t.Run(policy, func(t *testing.T) {
+			resetState(t)
+			config := testConfig()
+			config.Players.LateJoin = policy
+			game, players := newTestGame(t, config, "pizza", DefaultGameMode, "Ann", "Bob")
+			round := game.Rounds[0]
+			for _, p := range players {
+				if !game.IsParticipant(&round, p.Id) {
+					t.Errorf("%s does not take part in the first round", p.Name)
+				}
+			}
+			if game.allAnswered(&round) {
+				t.Errorf("answering is over before anybody answered")
+			}
+		})
+	}
+}
+
+func TestMinPlayers(t *testing.T) {
+	tests := []struct {
+		name       string
+		minPlayers int
+		answers    int
+		want       bool
+	}{
+		{name: "everybody answered", minPlayers: 2, answers: 2, want: true},
+		{name: "not everybody answered", minPlayers: 2, answers: 1, want: false},
+		{name: "fewer players than the minimum", minPlayers: 3, answers: 2, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetState(t)
+			config := testConfig()
+			config.Players.MinPlayers = tt.minPlayers
+			game, players := newTestGame(t, config, "pizza", DefaultGameMode, "Ann", "Bob")
+			roundId := game.Rounds[0].Id
+			for i := 0; i < tt.answers; i++ {
+				if err := AddAnswer(game.Id, players[i].Id, roundId, []string{"tacos", "sushi"}[i]); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if got := AllPlayerAnswered(game.Id, roundId); got != tt.want {
+				t.Errorf("AllPlayerAnswered() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package gamelogic
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// presenceInterval is how often players are checked for having gone away.
+const presenceInterval = time.Second
+
+// Seen records that the player is still there, e.g. because their page sent
+// a heartbeat. A player who was away is back and the rounds wait for them again.
+func Seen(playerId string) {
+	stateLock.Lock()
+	defer stateLock.Unlock()
+	player, ok := players[playerId]
+	if !ok {
+		return
+	}
+	player.LastSeen = time.Now()
+	wasAway := player.Away
+	player.Away = false
+	players[playerId] = player
+	if wasAway {
+		slog.Info("Player is back", "player", player)
+		for _, gameId := range gamesOf(playerId) {
+			setAway(gameId, playerId, false)
+			publish(EventPlayerBack, gameId, "", playerId)
+		}
+	}
+}
+
+// IsAway reports whether the rounds stop waiting for the player. Bots are
+// always there and players that no longer exist are gone.
+func IsAway(playerId string) bool {
+	stateLock.RLock()
+	defer stateLock.RUnlock()
+	player, ok := players[playerId]
+	return !ok || player.Away
+}
+
+// RunPresence marks the players that stopped sending requests for longer
+// than awayAfter as away until ctx is cancelled, and moves on the rounds that
+// only waited for them.
+func RunPresence(ctx context.Context, awayAfter time.Duration) {
+	if awayAfter <= 0 {
+		slog.Info("Presence is disabled, rounds wait for every player")
+		return
+	}
+	ticker := time.NewTicker(presenceInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			markAway(now, awayAfter)
+		}
+	}
+}
+
+func markAway(now time.Time, awayAfter time.Duration) {
+	stateLock.Lock()
+	defer stateLock.Unlock()
+	for playerId, player := range players {
+		if player.IsBot || player.Away || now.Sub(player.LastSeen) <= awayAfter {
+			continue
+		}
+		player.Away = true
+		players[playerId] = player
+		slog.Info("Player is away", "player", player, "lastSeen", player.LastSeen)
+		for _, gameId := range gamesOf(playerId) {
+			setAway(gameId, playerId, true)
+			publish(EventPlayerAway, gameId, "", playerId)
+			advance(gameId)
+		}
+	}
+}
+
+// advance moves the latest round of the game on when it only waits for
+// players who are away.
+func advance(gameId string) {
+	game, ok := games[gameId]
+	if !ok || game.IsComplete || len(game.Rounds) == 0 {
+		return
+	}
+	r := &game.Rounds[len(game.Rounds)-1]
+	switch {
+	case r.VotingStartedAt.IsZero():
+		if game.allAnswered(r) {
+			startVoting(gameId, r.Id)
+		}
+	case r.ResultsAt.IsZero():
+		if game.allVoted(r) {
+			finishVoting(gameId, r.Id)
+		}
+	default:
+		nextRound(gameId)
+	}
+}
+
+// setAway copies the away flag of the player into the game, which the rounds
+// check, so copies of the game handed out tell who is away as well.
+func setAway(gameId string, playerId string, away bool) {
+	game := games[gameId]
+	for i := range game.Players {
+		if game.Players[i].Id == playerId {
+			game.Players[i].Away = away
+		}
+	}
+	games[gameId] = game
+}
+
+// gamesOf returns the running games the player takes part in.
+func gamesOf(playerId string) []string {
+	ids := []string{}
+	for gameId, game := range games {
+		if game.IsComplete {
+			continue
+		}
+		for _, p := range game.Players {
+			if p.Id == playerId {
+				ids = append(ids, gameId)
+				break
+			}
+		}
+	}
+	return ids
+}
@@ -0,0 +1,110 @@
+package gamelogic
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMarkAway(t *testing.T) {
+	resetState(t)
+	awayAfter := testConfig().Players.AwayAfter
+	game, created := newTestGame(t, testConfig(), "pizza", DefaultGameMode, "Ann", "Bob", "Cat")
+	roundId := game.Rounds[0].Id
+	events, unsubscribe := Subscribe(game.Id)
+	defer unsubscribe()
+	for i, p := range created[:2] {
+		if err := AddAnswer(game.Id, p.Id, roundId, []string{"tacos", "sushi"}[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Only Cat went quiet, the round stops waiting for her
+	cat := created[2]
+	cat.LastSeen = time.Now().Add(-awayAfter - time.Second)
+	stateLock.Lock()
+	players[cat.Id] = cat
+	stateLock.Unlock()
+	markAway(time.Now(), awayAfter)
+	game, _ = GetGame(game.Id)
+	round := game.Rounds[0]
+	tests := []struct {
+		name string
+		got  bool
+		want bool
+	}{
+		{"Ann is away", IsAway(created[0].Id), false},
+		{"Cat is away", IsAway(created[2].Id), true},
+		{"the game tells Cat is away", game.isAway(created[2].Id), true},
+		{"voting started", game.RoundPhase(&round) == PhaseVoting, true},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+
+	Seen(created[2].Id)
+	game, _ = GetGame(game.Id)
+	if IsAway(created[2].Id) || game.isAway(created[2].Id) {
+		t.Errorf("Cat is still away after she was seen")
+	}
+	want := map[string]bool{EventPlayerAway: true, EventPlayerBack: true}
+	for len(want) > 0 {
+		select {
+		case e := <-events:
+			delete(want, e.Type)
+		case <-time.After(time.Second):
+			t.Fatalf("missing events %v", want)
+		}
+	}
+}
+
+// TestPresenceRace runs the presence loop against the requests of the
+// players, run it with -race.
+func TestPresenceRace(t *testing.T) {
+	resetState(t)
+	awayAfter := testConfig().Players.AwayAfter
+	game, created := newTestGame(t, testConfig(), "pizza", DefaultGameMode, "Ann", "Bob", "Cat")
+	answers := []string{"tacos", "sushi", "ramen"}
+
+	var wg sync.WaitGroup
+	for i, p := range created {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				Seen(p.Id)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if round, err := GetLatestRound(game.Id); err == nil {
+					AddAnswer(game.Id, p.Id, round.Id, answers[i])
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if g, ok := GetGame(game.Id); ok {
+					g.RoundPhase(&g.Rounds[len(g.Rounds)-1])
+				}
+				IsAway(p.Id)
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < 50; j++ {
+			markAway(time.Now().Add(awayAfter+time.Second), awayAfter)
+		}
+	}()
+	wg.Wait()
+
+	game, _ = GetGame(game.Id)
+	if len(game.Rounds) == 0 {
+		t.Fatal("game lost its rounds")
+	}
+}
@@ -4,13 +4,19 @@ import (
 	"log/slog"
 	"math/rand"
 	"strings"
+	"sync"
 )
 
 var shuffledQuestions []string
+var questionsLock sync.Mutex
 var playerNamePlaceholder string = "[player's name]"
 
+// GetRandomQuestion deals the questions in random order. Once every question
+// was asked the deck is shuffled again, so long games never run out.
 func GetRandomQuestion(playerName string) string {
-	if shuffledQuestions == nil {
+	questionsLock.Lock()
+	defer questionsLock.Unlock()
+	if len(shuffledQuestions) == 0 {
 		slog.Debug("Shuffling questions")
 		shuffledQuestions = shuffle(questions)
 	}
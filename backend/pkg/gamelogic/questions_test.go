@@ -0,0 +1,24 @@
+package gamelogic
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGetRandomQuestionReshuffles(t *testing.T) {
+	shuffledQuestions = nil
+	seen := map[string]int{}
+	for i := 0; i < 2*len(questions)+1; i++ {
+		q := GetRandomQuestion("Ann")
+		if strings.Contains(q, playerNamePlaceholder) {
+			t.Fatalf("question %q still has the placeholder", q)
+		}
+		seen[q]++
+	}
+	// Every question is asked once before any is asked again
+	for q, n := range seen {
+		if n < 2 {
+			t.Errorf("question %q asked %d times in two decks", q, n)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package gamelogic
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"log/slog"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Game passwords are never stored. A game keeps a salted argon2id hash to
+// check the password and a room key to find the game by it: an HMAC of the
+// password with the room secret, which is the key of the rooms index.
+//
+// The room secret comes from the config and is never saved with the state.
+// Without one every run makes up its own, the room keys of the games loaded
+// from the state file then no longer match and nobody new can join them.
+
+// rooms maps the room key of every running game to its id.
+var rooms map[string]string = make(map[string]string)
+
+// The argon2id parameters of the password hashes, the OWASP minimum.
+const (
+	argonTime      uint32 = 2
+	argonMemoryKiB uint32 = 19 * 1024
+	argonThreads   uint8  = 1
+	argonKeyLength uint32 = 32
+)
+
+func randomBytes(n int) []byte {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		panic("reading random bytes: " + err.Error())
+	}
+	return b
+}
+
+// NewRoomSecret makes up a room secret for a run without a configured one.
+func NewRoomSecret() string {
+	return hex.EncodeToString(randomBytes(32))
+}
+
+func roomKey(secret string, password string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(password))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// hashPassword is slow on purpose, callers must not hold stateLock.
+func hashPassword(salt []byte, password string) []byte {
+	return argon2.IDKey([]byte(password), salt, argonTime, argonMemoryKiB, argonThreads, argonKeyLength)
+}
+
+// setPassword keeps the hash and the room key of the password of the game.
+func (g *Game) setPassword(secret string, password string) {
+	g.PasswordSalt = randomBytes(16)
+	g.PasswordHash = hashPassword(g.PasswordSalt, password)
+	g.RoomKey = roomKey(secret, password)
+}
+
+// CheckPassword compares in constant time. It hashes the password, callers
+// must not hold stateLock.
+func (g *Game) CheckPassword(password string) bool {
+	hash := hashPassword(g.PasswordSalt, password)
+	return subtle.ConstantTimeCompare(hash, g.PasswordHash) == 1
+}
+
+// findRoom returns the running game with the password. Only the game with
+// its room key has the password checked, a wrong password costs no hashing.
+// Callers must not hold stateLock.
+func findRoom(secret string, password string) (Game, bool) {
+	stateLock.RLock()
+	game, ok := games[rooms[roomKey(secret, password)]]
+	stateLock.RUnlock()
+	if !ok || game.IsComplete || !game.CheckPassword(password) {
+		return Game{}, false
+	}
+	return game, true
+}
+
+// releaseRoom frees the password of a game that ended, so a new game can
+// use it.
+func releaseRoom(game Game) {
+	if rooms[game.RoomKey] == game.Id {
+		delete(rooms, game.RoomKey)
+	}
+}
+
+// rebuildRooms indexes the running games after loading the state. Their room
+// keys only hold with a configured secret, otherwise their players carry on
+// but nobody new can join them.
+func rebuildRooms(secretConfigured bool) {
+	rooms = make(map[string]string)
+	unreachable := 0
+	for gameId, game := range games {
+		switch {
+		case game.IsComplete:
+		case secretConfigured:
+			rooms[game.RoomKey] = gameId
+		default:
+			unreachable++
+		}
+	}
+	if unreachable > 0 {
+		slog.Warn("Restored games cannot be joined by password without game.room_secret", "games", unreachable)
+	}
+}
@@ -0,0 +1,147 @@
+package gamelogic
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRoomKey(t *testing.T) {
+	secret := NewRoomSecret()
+	key := roomKey(secret, "pizza")
+	tests := []struct {
+		name     string
+		secret   string
+		password string
+		same     bool
+	}{
+		{name: "same password", secret: secret, password: "pizza", same: true},
+		{name: "other password", secret: secret, password: "tacos", same: false},
+		{name: "case matters", secret: secret, password: "Pizza", same: false},
+		{name: "other secret", secret: NewRoomSecret(), password: "pizza", same: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := roomKey(tt.secret, tt.password); (got == key) != tt.same {
+				t.Errorf("roomKey(%q) = %s, want same as %s: %v", tt.password, got, key, tt.same)
+			}
+			if strings.Contains(roomKey(tt.secret, tt.password), tt.password) {
+				t.Errorf("room key contains the password")
+			}
+		})
+	}
+}
+
+func TestCheckPassword(t *testing.T) {
+	hashed := Game{}
+	hashed.setPassword(NewRoomSecret(), "pizza")
+
+	tests := []struct {
+		password string
+		want     bool
+	}{
+		{password: "pizza", want: true},
+		{password: "tacos", want: false},
+		{password: "Pizza", want: false},
+		{password: "", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.password, func(t *testing.T) {
+			if got := hashed.CheckPassword(tt.password); got != tt.want {
+				t.Errorf("CheckPassword(%q) = %v, want %v", tt.password, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindRoom(t *testing.T) {
+	resetState(t)
+	game, _ := newTestGame(t, testConfig(), "pizza", DefaultGameMode, "Ann", "Bob")
+	ended, _ := newTestGame(t, testConfig(), "tacos", DefaultGameMode, "Cat")
+	stateLock.Lock()
+	finishGame(ended.Id)
+	stateLock.Unlock()
+
+	tests := []struct {
+		password string
+		want     string // id of the game found, empty for none
+	}{
+		{password: "pizza", want: game.Id},
+		{password: "Pizza", want: ""},
+		{password: "tacos", want: ""},
+		{password: "sushi", want: ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.password, func(t *testing.T) {
+			found, ok := findRoom(testConfig().RoomSecret, tt.password)
+			if ok != (tt.want != "") || found.Id != tt.want {
+				t.Errorf("findRoom(%q) = %q, %v, want %q", tt.password, found.Id, ok, tt.want)
+			}
+		})
+	}
+}
+
+// TestRestoredRooms saves a running game and loads it again after a restart,
+// with and without a configured room secret.
+func TestRestoredRooms(t *testing.T) {
+	tests := []struct {
+		name     string
+		secret   string
+		joinable bool // new players find it by its password
+	}{
+		{name: "random secret", secret: "", joinable: false},
+		{name: "configured secret", secret: "the secret of this party", joinable: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetState(t)
+			// The server makes up a secret for every run unless one is configured
+			config := DefaultConfig()
+			config.RoomSecret = tt.secret
+			if tt.secret == "" {
+				config.RoomSecret = NewRoomSecret()
+			}
+			game, created := newTestGame(t, config, "pizza", DefaultGameMode, "Ann", "Bob")
+			path := filepath.Join(t.TempDir(), "state.json")
+			if err := SaveState(path); err != nil {
+				t.Fatal(err)
+			}
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if strings.Contains(string(data), config.RoomSecret) || strings.Contains(string(data), "pizza") {
+				t.Errorf("state file contains the room secret or the password")
+			}
+
+			if err := LoadState(path, tt.secret); err != nil {
+				t.Fatal(err)
+			}
+
+			// The players of the game carry on either way
+			round, err := GetLatestRound(game.Id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := AddAnswer(game.Id, created[0].Id, round.Id, "tacos"); err != nil {
+				t.Errorf("AddAnswer() after the restart = %v", err)
+			}
+
+			if tt.secret == "" {
+				config.RoomSecret = NewRoomSecret()
+			}
+			joiner, _ := CreatePlayer("Cat")
+			if _, err := JoinGame(config, "wrong", joiner.Id); err != ErrGameNotFound {
+				t.Errorf("JoinGame() with a wrong password = %v, want %v", err, ErrGameNotFound)
+			}
+			joined, err := JoinGame(config, "pizza", joiner.Id)
+			if tt.joinable && (err != nil || joined.Id != game.Id) {
+				t.Errorf("JoinGame() = %q, %v, want the restored game", joined.Id, err)
+			}
+			if !tt.joinable && err != ErrGameNotFound {
+				t.Errorf("JoinGame() = %q, %v, want %v", joined.Id, err, ErrGameNotFound)
+			}
+		})
+	}
+}
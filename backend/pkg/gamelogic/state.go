@@ -0,0 +1,83 @@
+package gamelogic
+
+import (
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// savedState leaves out the room secret, a copy of the state file must not
+// be enough to check passwords against the room keys.
+type savedState struct {
+	Games   map[string]Game
+	Players map[string]Player
+	History map[string]ArchivedGame
+}
+
+// SaveState writes every game and player to path as JSON. The file is
+// replaced atomically so a crash while saving keeps the previous state.
+func SaveState(path string) error {
+	stateLock.RLock()
+	defer stateLock.RUnlock()
+	data, err := json.Marshal(savedState{games, players, history})
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	slog.Info("Saved state", "path", path, "games", len(games), "players", len(players), "history", len(history))
+	return nil
+}
+
+// LoadState replaces the games and players with the ones saved at path. A
+// missing file is not an error, the server simply starts empty. roomSecret is
+// the configured one, empty when the server made up its own.
+func LoadState(path string, roomSecret string) error {
+	stateLock.Lock()
+	defer stateLock.Unlock()
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		slog.Info("No saved state to load", "path", path)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	state := savedState{}
+	if err := json.Unmarshal(data, &state); err != nil {
+		return err
+	}
+	if state.Games != nil {
+		games = state.Games
+	}
+	if state.Players != nil {
+		players = state.Players
+	}
+	if state.History != nil {
+		history = state.History
+	}
+	rebuildRooms(roomSecret != "")
+	backfillTimestamps(time.Now())
+	resetGauges()
+	slog.Info("Loaded state", "path", path, "games", len(games), "players", len(players), "history", len(history))
+	return nil
+}
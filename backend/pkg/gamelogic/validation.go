@@ -0,0 +1,94 @@
+package gamelogic
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ValidationError is returned when player input breaks a game rule. The
+// message is meant to be shown to the player as is.
+type ValidationError struct {
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+type AnswerRules struct {
+	MinLength       int // in characters, after whitespace normalization
+	MaxLength       int
+	FilterProfanity bool
+}
+
+// NormalizeAnswer trims the answer and collapses any run of whitespace,
+// including newlines and tabs, into a single space.
+func NormalizeAnswer(answerText string) string {
+	return strings.Join(strings.Fields(answerText), " ")
+}
+
+// validateAnswer applies the rules shared by every game mode. answerText must
+// already be normalized.
+func validateAnswer(rules AnswerRules, round *Round, playerId string, answerText string) error {
+	length := utf8.RuneCountInString(answerText)
+	if length == 0 {
+		return &ValidationError{"Answer cannot be empty."}
+	}
+	if length < rules.MinLength {
+		return &ValidationError{"Answer must be at least " + strconv.Itoa(rules.MinLength) + " characters long."}
+	}
+	if rules.MaxLength > 0 && length > rules.MaxLength {
+		return &ValidationError{"Answer must be at most " + strconv.Itoa(rules.MaxLength) + " characters long."}
+	}
+
+	for _, a := range round.Answers {
+		if a.Owner.Id != playerId && strings.EqualFold(a.Text, answerText) {
+			return &ValidationError{"Someone already gave that exact answer. Try something else."}
+		}
+	}
+
+	if rules.FilterProfanity && containsProfanity(answerText) {
+		return &ValidationError{"Keep it friendly, please rephrase your answer."}
+	}
+	return nil
+}
+
+func containsProfanity(text string) bool {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+	for _, w := range words {
+		if _, ok := profanity[w]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+var profanity = map[string]struct{}{
+	"arse":         {},
+	"arsehole":     {},
+	"asshole":      {},
+	"bastard":      {},
+	"bitch":        {},
+	"bollocks":     {},
+	"bullshit":     {},
+	"cock":         {},
+	"crap":         {},
+	"cunt":         {},
+	"dick":         {},
+	"dickhead":     {},
+	"fuck":         {},
+	"fucker":       {},
+	"fucking":      {},
+	"motherfucker": {},
+	"piss":         {},
+	"prick":        {},
+	"shit":         {},
+	"slut":         {},
+	"twat":         {},
+	"wanker":       {},
+	"whore":        {},
+}
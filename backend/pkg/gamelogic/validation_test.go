@@ -0,0 +1,83 @@
+package gamelogic
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeAnswer(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{in: "  pizza  ", want: "pizza"},
+		{in: "cold\n\tpizza", want: "cold pizza"},
+		{in: " \n\t ", want: ""},
+	}
+	for _, tt := range tests {
+		if got := NormalizeAnswer(tt.in); got != tt.want {
+			t.Errorf("NormalizeAnswer(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestValidateAnswer(t *testing.T) {
+	round := &Round{Answers: []Answer{
+		{Text: "Cold pizza", Owner: Player{Id: "bob"}},
+		{Text: "Karaoke", Owner: Player{Id: "ann"}},
+	}}
+	tests := []struct {
+		name    string
+		rules   AnswerRules
+		answer  string
+		wantErr string
+	}{
+		{name: "ok", rules: AnswerRules{MinLength: 1, MaxLength: 10}, answer: "Tacos"},
+		{name: "empty", rules: AnswerRules{MinLength: 1, MaxLength: 10}, answer: "", wantErr: "empty"},
+		{name: "too short", rules: AnswerRules{MinLength: 3, MaxLength: 10}, answer: "ab", wantErr: "at least 3"},
+		{name: "too long", rules: AnswerRules{MinLength: 1, MaxLength: 5}, answer: "abcdef", wantErr: "at most 5"},
+		{name: "length counts characters, not bytes", rules: AnswerRules{MinLength: 1, MaxLength: 5}, answer: "crème"},
+		{name: "no maximum", rules: AnswerRules{MinLength: 1}, answer: strings.Repeat("a", 500)},
+		{name: "duplicate of another player", rules: AnswerRules{MinLength: 1, MaxLength: 20}, answer: "cold PIZZA", wantErr: "already gave"},
+		{name: "own answer again", rules: AnswerRules{MinLength: 1, MaxLength: 20}, answer: "karaoke"},
+		{name: "profanity", rules: AnswerRules{MinLength: 1, MaxLength: 20, FilterProfanity: true}, answer: "Oh SHIT!", wantErr: "friendly"},
+		{name: "profanity unfiltered", rules: AnswerRules{MinLength: 1, MaxLength: 20}, answer: "Oh shit!"},
+		{name: "profanity inside a word", rules: AnswerRules{MinLength: 1, MaxLength: 20, FilterProfanity: true}, answer: "Scunthorpe"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateAnswer(tt.rules, round, "ann", tt.answer)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("validateAnswer(%q) = %v, want nil", tt.answer, err)
+				}
+				return
+			}
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("validateAnswer(%q) = %v, want a ValidationError", tt.answer, err)
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("validateAnswer(%q) = %q, want it to mention %q", tt.answer, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestContainsProfanity(t *testing.T) {
+	tests := []struct {
+		text string
+		want bool
+	}{
+		{text: "what a lovely day", want: false},
+		{text: "bullshit", want: true},
+		{text: "total-crap", want: true},
+		{text: "Dickens", want: false},
+	}
+	for _, tt := range tests {
+		if got := containsProfanity(tt.text); got != tt.want {
+			t.Errorf("containsProfanity(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
@@ -1,17 +1,92 @@
 package handlers
 
-import "net/http"
+import (
+	"html/template"
+	"io/fs"
+	"net/http"
+	"os"
+	"party-game/pkg/gamelogic"
+	"party-game/static"
+	"party-game/templates"
+	"time"
+)
 
-func AddHandlers(mux *http.ServeMux) {
-	mux.HandleFunc("/", HomePageHandler)
-	mux.HandleFunc("/create-player", CreatePlayerHandler)
-	mux.HandleFunc("/create-game", CreateGameHandler)
-	mux.HandleFunc("/join-game", JoinGameHandler)
-	mux.HandleFunc("/player-ready", PlayerReadyHandler)
-	mux.HandleFunc("/round-question", RoundQuestionHandler)
-	mux.HandleFunc("/submit-answer", SubmitAnswerHandler)
-	mux.HandleFunc("/round-choice", RoundChoiceHandler)
-	mux.HandleFunc("/submit-choice", SubmitChoiceHandler)
-	mux.HandleFunc("/round-results", RoundResultsHandler)
-	mux.HandleFunc("/new-round-ready", NewRoundReady)
+type Config struct {
+	// TemplatesDir is for development: when set the templates are read from
+	// it and parsed on every request instead of using the embedded ones.
+	TemplatesDir    string
+	LongPollTimeout time.Duration // how long the page handlers wait for the other players
+	// AdminToken protects the /debug endpoints, which are only registered
+	// when it is set. Pprof adds the profiler under /debug/pprof.
+	AdminToken string
+	Pprof      bool
+	Limits     LimitConfig
+	// Game holds the rules new games are created with and the room secret,
+	// which has to be set.
+	Game gamelogic.Config
+}
+
+// Handlers holds what the HTTP handlers need besides the game state.
+type Handlers struct {
+	config    Config
+	templates *templateRegistry
+	assets    assetRegistry
+	limits    *limits
+}
+
+// AddHandlers registers every page, API and debug handler on mux.
+func AddHandlers(mux *http.ServeMux, config Config) (*Handlers, error) {
+	var fsys fs.FS = templates.FS
+	if config.TemplatesDir != "" {
+		fsys = os.DirFS(config.TemplatesDir)
+	}
+	assets, err := newAssetRegistry(static.FS)
+	if err != nil {
+		return nil, err
+	}
+	funcs := template.FuncMap{"asset": assets.lookup}
+	registry, err := newTemplateRegistry(fsys, config.TemplatesDir != "", funcs)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &Handlers{config, registry, assets, newLimits(config.Limits)}
+	// Wrong methods get a 405 with an Allow header from the mux. Every POST
+	// needs the CSRF token the pages hand out.
+	mux.HandleFunc("GET /{$}", h.HomePageHandler)
+	mux.HandleFunc("GET /home", h.HomePageHandler)
+	mux.HandleFunc("POST /create-player", h.limitCreatePlayer(csrfProtect(h.CreatePlayerHandler)))
+	mux.HandleFunc("POST /create-game", h.limitJoin(csrfProtect(h.CreateGameHandler)))
+	mux.HandleFunc("POST /join-game", h.limitJoin(csrfProtect(h.JoinGameHandler)))
+	mux.HandleFunc("POST /player-ready", csrfProtect(h.PlayerReadyHandler))
+	mux.HandleFunc("GET /round-question", trackPresence(h.RoundQuestionHandler))
+	mux.HandleFunc("GET /round-question-wait", trackPresence(h.RoundQuestionWaitHandler))
+	mux.HandleFunc("POST /submit-answer", h.limitSubmit(csrfProtect(trackPresence(h.SubmitAnswerHandler))))
+	mux.HandleFunc("GET /round-choice", trackPresence(h.RoundChoiceHandler))
+	mux.HandleFunc("POST /submit-choice", h.limitSubmit(csrfProtect(trackPresence(h.SubmitChoiceHandler))))
+	mux.HandleFunc("GET /round-results", trackPresence(h.RoundResultsHandler))
+	mux.HandleFunc("POST /new-round-ready", csrfProtect(trackPresence(h.NewRoundReady)))
+	mux.HandleFunc("POST /end-game", csrfProtect(trackPresence(h.EndGameHandler)))
+	mux.HandleFunc("POST /heartbeat", csrfProtect(trackPresence(h.HeartbeatHandler)))
+	mux.HandleFunc("GET /history", h.HistoryHandler)
+	mux.HandleFunc("GET /history/{gameId}", h.HistoryGameHandler)
+	mux.HandleFunc("GET "+staticPrefix, h.StaticHandler)
+
+	for _, route := range h.apiRoutes() {
+		handler := route.Handler
+		handler = trackPresence(handler)
+		if route.Method != http.MethodGet {
+			handler = apiCSRFProtect(handler)
+		}
+		mux.HandleFunc(route.Method+" "+apiPrefix+route.Path, handler)
+	}
+	mux.HandleFunc("GET "+apiPrefix+"/openapi.json", h.OpenAPIHandler)
+	mux.HandleFunc(apiPrefix+"/", h.APINotFoundHandler)
+	h.addDebugHandlers(mux)
+	return h, nil
+}
+
+// Ready reports whether the handlers can render pages.
+func (h *Handlers) Ready() error {
+	return h.templates.check()
 }
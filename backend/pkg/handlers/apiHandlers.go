@@ -0,0 +1,587 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"party-game/pkg/gamelogic"
+	"strings"
+	"time"
+)
+
+const apiPrefix string = "/api/v1"
+
+// API clients identify themselves with this header instead of the cookie.
+const playerIdHeader string = "X-Player-Id"
+
+// The keep-alive also tells the server the player is still there
+var eventKeepAlive time.Duration = 10 * time.Second
+
+type APIPlayer struct {
+	Id   string `json:"id"`
+	Name string `json:"name"`
+	Away bool   `json:"away"` // the rounds do not wait for the player until they are back
+}
+
+type APIGame struct {
+	Id         string      `json:"id"`
+	Mode       string      `json:"mode"`
+	Players    []APIPlayer `json:"players"`
+	IsComplete bool        `json:"isComplete"`
+}
+
+type APIChoice struct {
+	Id   string `json:"id"`
+	Text string `json:"text"`
+}
+
+type APIRound struct {
+	Id       string `json:"id"`
+	Question string `json:"question"`
+	Phase    string `json:"phase"`
+	Answered bool   `json:"answered"`
+	Voted    bool   `json:"voted"`
+	// Players who joined late watch the round until the next one starts
+	Spectating bool        `json:"spectating"`
+	Choices    []APIChoice `json:"choices"` // the answers the player can vote for, empty while answering
+}
+
+type APIAnswer struct {
+	Id         string   `json:"id"`
+	Text       string   `json:"text"`
+	AuthorId   string   `json:"authorId"`
+	AuthorName string   `json:"authorName"`
+	VoterIds   []string `json:"voterIds"`
+	Points     int      `json:"points"`
+}
+
+type APIVote struct {
+	VoterId   string `json:"voterId"`
+	VoterName string `json:"voterName"`
+	AnswerId  string `json:"answerId"`
+}
+
+type APIScore struct {
+	PlayerId   string `json:"playerId"`
+	PlayerName string `json:"playerName"`
+	Points     int    `json:"points"`
+	Rank       int    `json:"rank"`
+	Delta      int    `json:"delta"`
+	RankChange int    `json:"rankChange"`
+}
+
+// APIError is the body of every failed API response.
+type APIError struct {
+	Status  int    `json:"status"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+type CreatePlayerRequest struct {
+	Name string `json:"name"`
+}
+
+type CreateGameRequest struct {
+	Password string `json:"password"`
+	Mode     string `json:"mode"`
+}
+
+type JoinGameRequest struct {
+	Password string `json:"password"`
+}
+
+type SubmitAnswerRequest struct {
+	Text string `json:"text"`
+}
+
+type SubmitVoteRequest struct {
+	AnswerId string `json:"answerId"`
+}
+
+func (h *Handlers) APICreatePlayerHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Entering APICreatePlayer handler")
+	var request CreatePlayerRequest
+	if !readJSON(w, r, &request) {
+		return
+	}
+	if request.Name == "" {
+		writeAPIError(w, http.StatusBadRequest, "Player name empty.")
+		return
+	}
+
+	player, ok := gamelogic.CreatePlayer(request.Name)
+	if !ok {
+		writeAPIError(w, http.StatusConflict, "Player with this name already exists.")
+		return
+	}
+	writeJSON(w, http.StatusCreated, toAPIPlayer(player))
+}
+
+func (h *Handlers) APIGetPlayerHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Entering APIGetPlayer handler")
+	if _, ok := apiPlayer(w, r); !ok {
+		return
+	}
+	player := gamelogic.GetPlayer(r.PathValue("playerId"))
+	if player.Id == "" {
+		writeAPIError(w, http.StatusNotFound, "Player does not exist.")
+		return
+	}
+	writeJSON(w, http.StatusOK, toAPIPlayer(player))
+}
+
+func (h *Handlers) APICreateGameHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Entering APICreateGame handler")
+	player, ok := apiPlayer(w, r)
+	if !ok {
+		return
+	}
+	var request CreateGameRequest
+	if !readJSON(w, r, &request) {
+		return
+	}
+	if request.Password == "" {
+		writeAPIError(w, http.StatusBadRequest, "Cannot create game with empty password.")
+		return
+	}
+	if _, ok := gamelogic.GetGameMode(request.Mode); !ok {
+		writeAPIError(w, http.StatusBadRequest, "Unknown game mode.")
+		return
+	}
+
+	if h.passwordLockedOut(w, r) {
+		return
+	}
+	if !h.canCreateGame(r) {
+		writeAPIError(w, http.StatusTooManyRequests, "Too many games running from your network, finish one first.")
+		return
+	}
+	game, created := gamelogic.CreateGame(h.config.Game, request.Password, player.Id, request.Mode)
+	if !created {
+		h.failedPasswordGuess(r)
+		writeAPIError(w, http.StatusConflict, "A game with the same password is already running.")
+		return
+	}
+	h.gameCreated(r, game.Id)
+	writeJSON(w, http.StatusCreated, toAPIGame(game))
+}
+
+func (h *Handlers) APIJoinGameHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Entering APIJoinGame handler")
+	player, ok := apiPlayer(w, r)
+	if !ok {
+		return
+	}
+	var request JoinGameRequest
+	if !readJSON(w, r, &request) {
+		return
+	}
+
+	if h.passwordLockedOut(w, r) {
+		return
+	}
+	game, err := gamelogic.JoinGame(h.config.Game, request.Password, player.Id)
+	if errors.Is(err, gamelogic.ErrGameNotFound) {
+		h.failedPasswordGuess(r)
+	}
+	if errors.Is(err, gamelogic.ErrGameFull) || errors.Is(err, gamelogic.ErrGameStarted) {
+		writeAPIError(w, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	game, _ = gamelogic.GetGame(game.Id)
+	writeJSON(w, http.StatusOK, toAPIGame(game))
+}
+
+func (h *Handlers) APIGetGameHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Entering APIGetGame handler")
+	game, _, ok := apiGameAndPlayer(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, toAPIGame(game))
+}
+
+func (h *Handlers) APIListRoundsHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Entering APIListRounds handler")
+	game, player, ok := apiGameAndPlayer(w, r)
+	if !ok {
+		return
+	}
+	rounds := []APIRound{}
+	for i := range game.Rounds {
+		rounds = append(rounds, toAPIRound(&game, &game.Rounds[i], player.Id))
+	}
+	writeJSON(w, http.StatusOK, rounds)
+}
+
+func (h *Handlers) APICurrentRoundHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Entering APICurrentRound handler")
+	game, player, ok := apiGameAndPlayer(w, r)
+	if !ok {
+		return
+	}
+	if len(game.Rounds) == 0 {
+		writeAPIError(w, http.StatusNotFound, "Game has no rounds yet.")
+		return
+	}
+	writeJSON(w, http.StatusOK, toAPIRound(&game, &game.Rounds[len(game.Rounds)-1], player.Id))
+}
+
+func (h *Handlers) APIGetRoundHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Entering APIGetRound handler")
+	game, player, round, ok := apiGamePlayerAndRound(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, toAPIRound(&game, round, player.Id))
+}
+
+// APIListAnswersHandler reveals the answers with their authors once everybody voted.
+func (h *Handlers) APIListAnswersHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Entering APIListAnswers handler")
+	game, _, round, ok := apiGamePlayerAndRound(w, r)
+	if !ok {
+		return
+	}
+	if !isRevealed(&game, round) {
+		writeAPIError(w, http.StatusConflict, "Answers are revealed once everybody voted.")
+		return
+	}
+
+	roundScores := game.GameMode().ComputeScores(&game, round)
+	answers := []APIAnswer{}
+	for _, a := range round.Answers {
+		voterIds := []string{}
+		for _, v := range a.Voters {
+			voterIds = append(voterIds, v.Id)
+		}
+		answers = append(answers, APIAnswer{a.Id, a.Text, a.Owner.Id, a.Owner.Name, voterIds, roundScores[a.Owner.Id]})
+	}
+	writeJSON(w, http.StatusOK, answers)
+}
+
+func (h *Handlers) APISubmitAnswerHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Entering APISubmitAnswer handler")
+	game, player, round, ok := apiGamePlayerAndRound(w, r)
+	if !ok {
+		return
+	}
+	var request SubmitAnswerRequest
+	if !readJSON(w, r, &request) {
+		return
+	}
+	if game.RoundPhase(round) != gamelogic.PhaseAnswering {
+		writeAPIError(w, http.StatusConflict, "Answering is over for this round.")
+		return
+	}
+
+	err := gamelogic.AddAnswer(game.Id, player.Id, round.Id, request.Text)
+	var validationErr *gamelogic.ValidationError
+	if errors.As(err, &validationErr) {
+		writeAPIError(w, http.StatusUnprocessableEntity, validationErr.Message)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Could not add answer", "error", err)
+		writeAPIError(w, http.StatusInternalServerError, "Could not add answer.")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// APIListVotesHandler reveals who voted for which answer once everybody voted.
+func (h *Handlers) APIListVotesHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Entering APIListVotes handler")
+	game, _, round, ok := apiGamePlayerAndRound(w, r)
+	if !ok {
+		return
+	}
+	if !isRevealed(&game, round) {
+		writeAPIError(w, http.StatusConflict, "Votes are revealed once everybody voted.")
+		return
+	}
+
+	votes := []APIVote{}
+	for _, a := range round.Answers {
+		for _, v := range a.Voters {
+			votes = append(votes, APIVote{v.Id, v.Name, a.Id})
+		}
+	}
+	writeJSON(w, http.StatusOK, votes)
+}
+
+func (h *Handlers) APISubmitVoteHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Entering APISubmitVote handler")
+	game, player, round, ok := apiGamePlayerAndRound(w, r)
+	if !ok {
+		return
+	}
+	var request SubmitVoteRequest
+	if !readJSON(w, r, &request) {
+		return
+	}
+
+	if game.RoundPhase(round) != gamelogic.PhaseVoting {
+		writeAPIError(w, http.StatusConflict, "Voting is not open for this round.")
+		return
+	}
+	if round.HasVoted(player.Id) {
+		writeAPIError(w, http.StatusConflict, "You already voted in this round.")
+		return
+	}
+
+	err := gamelogic.AddChoice(game.Id, player.Id, round.Id, request.AnswerId)
+	var validationErr *gamelogic.ValidationError
+	if errors.As(err, &validationErr) {
+		writeAPIError(w, http.StatusUnprocessableEntity, validationErr.Message)
+		return
+	}
+	if err != nil {
+		slog.InfoContext(r.Context(), "Could not add choice", "error", err)
+		writeAPIError(w, http.StatusUnprocessableEntity, "Answer "+request.AnswerId+" is not part of this round.")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// APIHeartbeatHandler only tells the server the player is still there, for
+// clients that do not keep an event stream open.
+func (h *Handlers) APIHeartbeatHandler(w http.ResponseWriter, r *http.Request) {
+	if _, ok := apiPlayer(w, r); !ok {
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handlers) APIReadyHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Entering APIReady handler")
+	game, player, ok := apiGameAndPlayer(w, r)
+	if !ok {
+		return
+	}
+	gamelogic.PlayerReady(game.Id, player.Id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// APIEndGameHandler ends the game once the results of the latest round are
+// out and returns it complete.
+func (h *Handlers) APIEndGameHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Entering APIEndGame handler")
+	game, player, ok := apiGameAndPlayer(w, r)
+	if !ok {
+		return
+	}
+	err := gamelogic.EndGame(game.Id, player.Id)
+	if errors.Is(err, gamelogic.ErrRoundInProgress) {
+		writeAPIError(w, http.StatusConflict, "The round is still being played, end the game after its results.")
+		return
+	}
+	if err != nil {
+		writeAPIError(w, http.StatusNotFound, "Game does not exist.")
+		return
+	}
+	game, _ = gamelogic.GetGame(game.Id)
+	writeJSON(w, http.StatusOK, toAPIGame(game))
+}
+
+// APIScoresHandler returns the standings after the round given in the roundId
+// query parameter, or after the latest round.
+func (h *Handlers) APIScoresHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Entering APIScores handler")
+	game, _, ok := apiGameAndPlayer(w, r)
+	if !ok {
+		return
+	}
+	scores := []APIScore{}
+	if len(game.Rounds) == 0 {
+		writeJSON(w, http.StatusOK, scores)
+		return
+	}
+
+	roundId := r.URL.Query().Get("roundId")
+	if roundId == "" {
+		roundId = game.Rounds[len(game.Rounds)-1].Id
+	}
+	leaderboard, err := gamelogic.GetLeaderboard(game.Id, roundId)
+	if err != nil {
+		writeAPIError(w, http.StatusNotFound, "Round does not exist.")
+		return
+	}
+	for _, e := range leaderboard {
+		scores = append(scores, APIScore{e.PlayerId, e.PlayerName, e.Points, e.Rank, e.Delta, e.RankChange})
+	}
+	writeJSON(w, http.StatusOK, scores)
+}
+
+// APIEventsHandler streams the game events as server-sent events until the
+// client goes away.
+func (h *Handlers) APIEventsHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Entering APIEvents handler")
+	game, player, ok := apiGameAndPlayer(w, r)
+	if !ok {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeAPIError(w, http.StatusInternalServerError, "Streaming is not supported.")
+		return
+	}
+
+	events, unsubscribe := gamelogic.Subscribe(game.Id)
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-keepAlive.C:
+			gamelogic.Seen(player.Id)
+			w.Write([]byte(": keep-alive\n\n"))
+			flusher.Flush()
+		case event, ok := <-events:
+			if !ok {
+				// The game was deleted
+				return
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				slog.ErrorContext(r.Context(), "Could not encode event", "event", event, "error", err)
+				continue
+			}
+			w.Write([]byte("event: " + event.Type + "\ndata: " + string(data) + "\n\n"))
+			flusher.Flush()
+		}
+	}
+}
+
+// APINotFoundHandler answers unknown API paths with an API error instead of
+// the home page.
+func (h *Handlers) APINotFoundHandler(w http.ResponseWriter, r *http.Request) {
+	writeAPIError(w, http.StatusNotFound, "No API endpoint "+r.Method+" "+r.URL.Path+".")
+}
+
+// apiPlayer returns the player making the request or writes an error.
+func apiPlayer(w http.ResponseWriter, r *http.Request) (gamelogic.Player, bool) {
+	playerId := r.Header.Get(playerIdHeader)
+	if playerId == "" {
+		if cookie, err := r.Cookie(playerIdCookie); err == nil {
+			playerId = cookie.Value
+		}
+	}
+	player := gamelogic.GetPlayer(playerId)
+	if playerId == "" || player.Id == "" {
+		writeAPIError(w, http.StatusUnauthorized, "Player not identified. Create one and send its id in the "+playerIdHeader+" header.")
+		return gamelogic.Player{}, false
+	}
+	return player, true
+}
+
+// apiGameAndPlayer returns the game in the path and the requesting player,
+// who has to be part of that game.
+func apiGameAndPlayer(w http.ResponseWriter, r *http.Request) (gamelogic.Game, gamelogic.Player, bool) {
+	player, ok := apiPlayer(w, r)
+	if !ok {
+		return gamelogic.Game{}, gamelogic.Player{}, false
+	}
+	game, ok := gamelogic.GetGame(r.PathValue("gameId"))
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, "Game does not exist.")
+		return gamelogic.Game{}, gamelogic.Player{}, false
+	}
+	for _, p := range game.Players {
+		if p.Id == player.Id {
+			return game, player, true
+		}
+	}
+	writeAPIError(w, http.StatusForbidden, "You are not part of this game.")
+	return gamelogic.Game{}, gamelogic.Player{}, false
+}
+
+// apiGamePlayerAndRound additionally looks up the round in the path.
+func apiGamePlayerAndRound(w http.ResponseWriter, r *http.Request) (gamelogic.Game, gamelogic.Player, *gamelogic.Round, bool) {
+	game, player, ok := apiGameAndPlayer(w, r)
+	if !ok {
+		return gamelogic.Game{}, gamelogic.Player{}, nil, false
+	}
+	roundId := r.PathValue("roundId")
+	for i := range game.Rounds {
+		if game.Rounds[i].Id == roundId {
+			return game, player, &game.Rounds[i], true
+		}
+	}
+	writeAPIError(w, http.StatusNotFound, "Round does not exist.")
+	return gamelogic.Game{}, gamelogic.Player{}, nil, false
+}
+
+func isRevealed(game *gamelogic.Game, round *gamelogic.Round) bool {
+	phase := game.RoundPhase(round)
+	return phase == gamelogic.PhaseResults || phase == gamelogic.PhaseFinished
+}
+
+func toAPIPlayer(player gamelogic.Player) APIPlayer {
+	return APIPlayer{player.Id, player.Name, gamelogic.IsAway(player.Id)}
+}
+
+func toAPIGame(game gamelogic.Game) APIGame {
+	players := []APIPlayer{}
+	for _, p := range game.Players {
+		players = append(players, toAPIPlayer(p))
+	}
+	return APIGame{game.Id, game.Mode, players, game.IsComplete}
+}
+
+func toAPIRound(game *gamelogic.Game, round *gamelogic.Round, playerId string) APIRound {
+	_, answered := round.AnswerOf(playerId)
+	response := APIRound{
+		Id:         round.Id,
+		Question:   round.Question,
+		Phase:      game.RoundPhase(round),
+		Answered:   answered,
+		Voted:      round.HasVoted(playerId),
+		Spectating: !game.CanPlay(round, playerId),
+		Choices:    []APIChoice{},
+	}
+	// Answers are only shown once everybody answered, and never the player's own
+	if response.Phase != gamelogic.PhaseAnswering {
+		for _, a := range round.Answers {
+			if a.Owner.Id != playerId {
+				response.Choices = append(response.Choices, APIChoice{a.Id, a.Text})
+			}
+		}
+	}
+	return response
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		slog.InfoContext(r.Context(), "Cannot decode request body", "error", err)
+		writeAPIError(w, http.StatusBadRequest, "Request body is not valid JSON.")
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		slog.Error("Cannot encode response", "error", err)
+	}
+}
+
+func writeAPIError(w http.ResponseWriter, status int, message string) {
+	code := strings.ReplaceAll(strings.ToLower(http.StatusText(status)), " ", "_")
+	writeJSON(w, status, APIError{status, code, message})
+}
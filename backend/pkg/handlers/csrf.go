@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"log/slog"
+	"net/http"
+)
+
+// CSRF protection uses the double submit pattern: every page sets a random
+// token in a cookie and puts the same token in the hx-headers of the body,
+// so htmx sends it back with every request. Another site can make the
+// browser send the cookie, but it can neither read it nor set the header.
+const CSRFCookie string = "csrf-token"
+const CSRFHeader string = "X-CSRF-Token"
+const csrfField string = "csrf-token" // for plain forms that do not go through htmx
+
+// csrfToken returns the token of the request's cookie, creating the cookie
+// when the request does not have one yet.
+func csrfToken(w http.ResponseWriter, r *http.Request) string {
+	if cookie, err := r.Cookie(CSRFCookie); err == nil && cookie.Value != "" {
+		return cookie.Value
+	}
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic("reading random bytes: " + err.Error())
+	}
+	token := base64.RawURLEncoding.EncodeToString(b)
+	http.SetCookie(w, &http.Cookie{
+		Name:     CSRFCookie,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	return token
+}
+
+// validCSRFToken reports whether the request sends back the token of its
+// cookie in the header or the form.
+func validCSRFToken(r *http.Request) bool {
+	cookie, err := r.Cookie(CSRFCookie)
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+	token := r.Header.Get(CSRFHeader)
+	if token == "" {
+		token = r.PostFormValue(csrfField)
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(cookie.Value)) == 1
+}
+
+// csrfProtect rejects page form posts without a valid token.
+func csrfProtect(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !validCSRFToken(r) {
+			slog.WarnContext(r.Context(), "Rejected request without a valid CSRF token", "path", r.URL.Path)
+			http.Error(w, "The page expired, reload it and try again.", http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// apiCSRFProtect only checks API calls that identify the player by the
+// cookie. Clients sending the player header cannot be forged, browsers do
+// not let other sites set it.
+func apiCSRFProtect(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_, err := r.Cookie(playerIdCookie)
+		if r.Header.Get(playerIdHeader) == "" && err == nil && !validCSRFToken(r) {
+			slog.WarnContext(r.Context(), "Rejected API request without a valid CSRF token", "path", r.URL.Path)
+			writeAPIError(w, http.StatusForbidden, "Requests authenticated by cookie need the "+CSRFHeader+" header.")
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestCSRFProtect(t *testing.T) {
+	const token = "token-of-the-cookie"
+	tests := []struct {
+		name   string
+		cookie string
+		header string
+		form   string
+		want   int
+	}{
+		{name: "header matches cookie", cookie: token, header: token, want: http.StatusOK},
+		{name: "form field matches cookie", cookie: token, form: token, want: http.StatusOK},
+		{name: "no cookie", header: token, want: http.StatusForbidden},
+		{name: "no token", cookie: token, want: http.StatusForbidden},
+		{name: "header differs", cookie: token, header: "forged", want: http.StatusForbidden},
+		{name: "form field differs", cookie: token, form: "forged", want: http.StatusForbidden},
+		{name: "empty cookie and header", cookie: "", header: "", want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			form := url.Values{}
+			if tt.form != "" {
+				form.Set(csrfField, tt.form)
+			}
+			r := httptest.NewRequest(http.MethodPost, "/submit-answer", strings.NewReader(form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			if tt.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: CSRFCookie, Value: tt.cookie})
+			}
+			if tt.header != "" {
+				r.Header.Set(CSRFHeader, tt.header)
+			}
+			w := httptest.NewRecorder()
+			csrfProtect(func(w http.ResponseWriter, r *http.Request) {})(w, r)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestAPICSRFProtect(t *testing.T) {
+	const token = "token-of-the-cookie"
+	tests := []struct {
+		name         string
+		playerHeader bool
+		playerCookie bool
+		csrfHeader   string
+		want         int
+	}{
+		{name: "player header needs no token", playerHeader: true, want: http.StatusOK},
+		{name: "no player at all", want: http.StatusOK},
+		{name: "player cookie with token", playerCookie: true, csrfHeader: token, want: http.StatusOK},
+		{name: "player cookie without token", playerCookie: true, want: http.StatusForbidden},
+		{name: "player cookie with a forged token", playerCookie: true, csrfHeader: "forged", want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/players", nil)
+			r.AddCookie(&http.Cookie{Name: CSRFCookie, Value: token})
+			if tt.playerHeader {
+				r.Header.Set(playerIdHeader, "player")
+			}
+			if tt.playerCookie {
+				r.AddCookie(&http.Cookie{Name: playerIdCookie, Value: "player"})
+			}
+			if tt.csrfHeader != "" {
+				r.Header.Set(CSRFHeader, tt.csrfHeader)
+			}
+			w := httptest.NewRecorder()
+			apiCSRFProtect(func(w http.ResponseWriter, r *http.Request) {})(w, r)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestCSRFTokenKeepsTheCookie(t *testing.T) {
+	w := httptest.NewRecorder()
+	first := csrfToken(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Value != first || !cookies[0].HttpOnly {
+		t.Fatalf("cookies = %v, want an HttpOnly %s cookie with the token", cookies, CSRFCookie)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.AddCookie(cookies[0])
+	w = httptest.NewRecorder()
+	if again := csrfToken(w, r); again != first {
+		t.Errorf("csrfToken() = %q, want the token of the cookie %q", again, first)
+	}
+	if len(w.Result().Cookies()) != 0 {
+		t.Errorf("csrfToken() set a new cookie although the request had one")
+	}
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"log/slog"
+	"net/http"
+	"net/http/pprof"
+	"party-game/pkg/gamelogic"
+	"sort"
+	"strings"
+	"time"
+)
+
+type DebugPlayer struct {
+	Id     string `json:"id"`
+	Name   string `json:"name"`
+	Ready  bool   `json:"ready"`
+	IsBot  bool   `json:"isBot"`
+	Points int    `json:"points"`
+}
+
+type DebugRound struct {
+	Id              string      `json:"id"`
+	Question        string      `json:"question"`
+	Phase           string      `json:"phase"`
+	Answers         []APIAnswer `json:"answers"`
+	ChoiceCount     int         `json:"choiceCount"`
+	StartedAt       time.Time   `json:"startedAt"`
+	VotingStartedAt time.Time   `json:"votingStartedAt"`
+	ResultsAt       time.Time   `json:"resultsAt"`
+}
+
+// DebugGame is everything about a game except its password.
+type DebugGame struct {
+	Id         string        `json:"id"`
+	Mode       string        `json:"mode"`
+	Started    bool          `json:"started"`
+	IsComplete bool          `json:"isComplete"`
+	Players    []DebugPlayer `json:"players"`
+	Rounds     []DebugRound  `json:"rounds"`
+}
+
+// addDebugHandlers registers the admin endpoints. They are left out when no
+// admin token is configured, so they cannot be reached without one.
+func (h *Handlers) addDebugHandlers(mux *http.ServeMux) {
+	if h.config.AdminToken == "" {
+		return
+	}
+	mux.Handle("GET /debug/games", h.requireAdmin(http.HandlerFunc(h.DebugGamesHandler)))
+	mux.Handle("GET /debug/games/{gameId}", h.requireAdmin(http.HandlerFunc(h.DebugGameHandler)))
+
+	if h.config.Pprof {
+		mux.Handle("GET /debug/pprof/", h.requireAdmin(http.HandlerFunc(pprof.Index)))
+		mux.Handle("GET /debug/pprof/cmdline", h.requireAdmin(http.HandlerFunc(pprof.Cmdline)))
+		mux.Handle("GET /debug/pprof/profile", h.requireAdmin(http.HandlerFunc(pprof.Profile)))
+		mux.Handle("GET /debug/pprof/symbol", h.requireAdmin(http.HandlerFunc(pprof.Symbol)))
+		mux.Handle("GET /debug/pprof/trace", h.requireAdmin(http.HandlerFunc(pprof.Trace)))
+	}
+}
+
+// requireAdmin only lets requests through that send the admin token as
+// "Authorization: Bearer <token>".
+func (h *Handlers) requireAdmin(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.config.AdminToken)) != 1 {
+			slog.WarnContext(r.Context(), "Rejected debug request without a valid admin token", "path", r.URL.Path)
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeAPIError(w, http.StatusUnauthorized, "Admin token required.")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// DebugGamesHandler lists every game, newest rounds included.
+func (h *Handlers) DebugGamesHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Entering DebugGames handler")
+	games := []DebugGame{}
+	for _, game := range gamelogic.ListGames() {
+		games = append(games, toDebugGame(game))
+	}
+	sort.Slice(games, func(i, j int) bool { return games[i].Id < games[j].Id })
+	writeJSON(w, http.StatusOK, games)
+}
+
+func (h *Handlers) DebugGameHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Entering DebugGame handler")
+	game, ok := gamelogic.GetGame(r.PathValue("gameId"))
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, "Game does not exist.")
+		return
+	}
+	writeJSON(w, http.StatusOK, toDebugGame(game))
+}
+
+func toDebugGame(game gamelogic.Game) DebugGame {
+	players := []DebugPlayer{}
+	for _, p := range game.Players {
+		players = append(players, DebugPlayer{p.Id, p.Name, p.PlayerReady, p.IsBot, game.Score[p.Id]})
+	}
+	rounds := []DebugRound{}
+	for i := range game.Rounds {
+		round := &game.Rounds[i]
+		scores := game.GameMode().ComputeScores(&game, round)
+		answers := []APIAnswer{}
+		for _, a := range round.Answers {
+			voterIds := []string{}
+			for _, v := range a.Voters {
+				voterIds = append(voterIds, v.Id)
+			}
+			answers = append(answers, APIAnswer{a.Id, a.Text, a.Owner.Id, a.Owner.Name, voterIds, scores[a.Owner.Id]})
+		}
+		rounds = append(rounds, DebugRound{
+			Id:              round.Id,
+			Question:        round.Question,
+			Phase:           game.RoundPhase(round),
+			Answers:         answers,
+			ChoiceCount:     round.ChoiceCount,
+			StartedAt:       round.StartedAt,
+			VotingStartedAt: round.VotingStartedAt,
+			ResultsAt:       round.ResultsAt,
+		})
+	}
+	return DebugGame{game.Id, game.Mode, game.Started, game.IsComplete, players, rounds}
+}
@@ -1,28 +1,76 @@
 package handlers
 
 import (
-	"html/template"
+	"context"
+	"errors"
 	"log/slog"
 	"net/http"
 	"party-game/pkg/gamelogic"
 	"time"
 )
 
-var timeoutTime time.Duration = 60 * time.Second
+// waitUntil polls done every second until it returns true or the long poll
+// timeout passes. It returns early when the request is cancelled, which also happens
+// when the server drains connections before shutting down.
+func (h *Handlers) waitUntil(ctx context.Context, done func() bool) {
+	controlTime := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			slog.DebugContext(ctx, "Stopped waiting, request cancelled", "error", ctx.Err())
+			return
+		case <-time.After(time.Second):
+		}
+		if done() {
+			return
+		}
+		if time.Since(controlTime) > h.config.LongPollTimeout {
+			return
+		}
+	}
+}
 
-func RoundQuestionHandler(w http.ResponseWriter, r *http.Request) {
-	slog.Debug("Entering RoundQuestion handler")
+type RoundQuestionData struct {
+	Question  string
+	MaxLength int
+	Error     string
+	Waiting   bool // the player joined late and watches until they can play
+}
+
+// mustWait reports whether a player who joined late has to wait before they
+// can answer the latest round of the game.
+func mustWait(gameId string, playerId string) bool {
+	game, ok := gamelogic.GetGame(gameId)
+	if !ok || game.IsComplete || len(game.Rounds) == 0 {
+		return false
+	}
+	round := &game.Rounds[len(game.Rounds)-1]
+	if game.IsParticipant(round, playerId) {
+		return false
+	}
+	return !game.CanPlay(round, playerId) || game.RoundPhase(round) != gamelogic.PhaseAnswering
+}
+
+func (h *Handlers) RoundQuestionHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Entering RoundQuestion handler")
 	gameId, err := r.Cookie(gameIdCookie)
 	if err != nil {
 		http.Error(w, "Error. Check server logs.", http.StatusInternalServerError)
 		return
 	}
 
+	playerId, err := r.Cookie(playerIdCookie)
+	if err != nil {
+		http.Error(w, "Could not find player id cookie.", http.StatusBadRequest)
+		return
+	}
+
 	round, err := gamelogic.GetLatestRound(gameId.Value)
 	if err != nil {
 		http.Error(w, "Could not get latest round", http.StatusInternalServerError)
 		return
 	}
+	game, _ := gamelogic.GetGame(gameId.Value)
 
 	http.SetCookie(w, &http.Cookie{
 		Name:  roundIdCookie,
@@ -30,18 +78,47 @@ func RoundQuestionHandler(w http.ResponseWriter, r *http.Request) {
 		Path:  "/",
 	})
 
-	tmpl := template.Must(template.ParseFiles("templates/round-question.html"))
-	tmpl.Execute(w, round)
-	slog.Debug("Serving round question template", "round", round)
+	waiting := mustWait(gameId.Value, playerId.Value)
+	responseData := RoundQuestionData{round.Question, game.Rules.Answers.MaxLength, "", waiting}
+
+	h.renderPage(w, r, "round-question.html", responseData)
+	slog.DebugContext(r.Context(), "Serving round question template", "round", round)
 }
 
-func SubmitAnswerHandler(w http.ResponseWriter, r *http.Request) {
-	slog.Debug("Entering SubmitAnswer handler")
-	if !IsPost(r) {
-		http.Error(w, "Error. Check server logs.", http.StatusBadRequest)
+// RoundQuestionWaitHandler holds a late joiner until the next round starts,
+// or the current one lets them play, and sends them back to the question.
+func (h *Handlers) RoundQuestionWaitHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Entering RoundQuestionWait handler")
+	gameId, err := r.Cookie(gameIdCookie)
+	if err != nil {
+		http.Error(w, "Could not find game id cookie.", http.StatusBadRequest)
 		return
 	}
 
+	playerId, err := r.Cookie(playerIdCookie)
+	if err != nil {
+		http.Error(w, "Could not find player id cookie.", http.StatusBadRequest)
+		return
+	}
+
+	h.waitUntil(r.Context(), func() bool {
+		return !mustWait(gameId.Value, playerId.Value)
+	})
+
+	if game, ok := gamelogic.GetGame(gameId.Value); ok && game.IsComplete {
+		w.Header().Set("HX-Redirect", "/round-results")
+		w.Write(nil)
+		return
+	}
+
+	// After a timeout the question page starts waiting again
+	w.Header().Set("HX-Redirect", "/round-question")
+	w.Write(nil)
+	slog.DebugContext(r.Context(), "Redirect to /round-question")
+}
+
+func (h *Handlers) SubmitAnswerHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Entering SubmitAnswer handler")
 	gameId, err := r.Cookie(gameIdCookie)
 	if err != nil {
 		http.Error(w, "Could not find game id cookie.", http.StatusBadRequest)
@@ -63,35 +140,35 @@ func SubmitAnswerHandler(w http.ResponseWriter, r *http.Request) {
 	answer := r.PostFormValue("player-answer")
 
 	err = gamelogic.AddAnswer(gameId.Value, playerId.Value, roundId.Value, answer)
+	var validationErr *gamelogic.ValidationError
+	if errors.As(err, &validationErr) {
+		slog.InfoContext(r.Context(), "Answer rejected", "reason", validationErr.Message)
+		h.render(w, http.StatusUnprocessableEntity, "round-question.html", "answer-error", RoundQuestionData{Error: validationErr.Message})
+		return
+	}
 	if err != nil {
 		http.Error(w, "Could not add answer. Check server logs", http.StatusInternalServerError)
-		slog.Error("Could not add answer", "error", err)
+		slog.ErrorContext(r.Context(), "Could not add answer", "error", err)
 		return
 	}
 
-	controlTime := time.Now()
-	for {
-		time.Sleep(time.Second)
-		if gamelogic.AllPlayerAnswered(gameId.Value, roundId.Value) {
-			break
-		}
-		if time.Since(controlTime) > timeoutTime {
-			break
-		}
-	}
+	h.waitUntil(r.Context(), func() bool {
+		return gamelogic.AllPlayerAnswered(gameId.Value, roundId.Value)
+	})
 
 	w.Header().Set("HX-Redirect", "/round-choice")
 	w.Write(nil)
-	slog.Debug("Redirect to /round-choice")
+	slog.DebugContext(r.Context(), "Redirect to /round-choice")
 }
 
 type RoundChoiceData struct {
 	Question string
 	Choices  []gamelogic.Answer
+	Error    string // why the vote was rejected
 }
 
-func RoundChoiceHandler(w http.ResponseWriter, r *http.Request) {
-	slog.Debug("Entering RoundChoice handler")
+func (h *Handlers) RoundChoiceHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Entering RoundChoice handler")
 	gameId, err := r.Cookie(gameIdCookie)
 	if err != nil {
 		http.Error(w, "Error. Check server logs.", http.StatusBadRequest)
@@ -115,15 +192,14 @@ func RoundChoiceHandler(w http.ResponseWriter, r *http.Request) {
 			answersCopy = append(answersCopy, a)
 		}
 	}
-	responseData := RoundChoiceData{round.Question, answersCopy}
+	responseData := RoundChoiceData{Question: round.Question, Choices: answersCopy}
 
-	tmpl := template.Must(template.ParseFiles("templates/round-choices.html"))
-	tmpl.Execute(w, responseData)
-	slog.Debug("Serving round choice template", "responseData", responseData)
+	h.renderPage(w, r, "round-choices.html", responseData)
+	slog.DebugContext(r.Context(), "Serving round choice template", "responseData", responseData)
 }
 
-func SubmitChoiceHandler(w http.ResponseWriter, r *http.Request) {
-	slog.Debug("Entering SubmitChoice handler")
+func (h *Handlers) SubmitChoiceHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Entering SubmitChoice handler")
 	gameId, err := r.Cookie(gameIdCookie)
 	if err != nil {
 		http.Error(w, "Could not find game id cookie.", http.StatusBadRequest)
@@ -149,37 +225,37 @@ func SubmitChoiceHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	err = gamelogic.AddChoice(gameId.Value, playerId.Value, roundId.Value, choiceId)
+	var validationErr *gamelogic.ValidationError
+	if errors.As(err, &validationErr) {
+		slog.InfoContext(r.Context(), "Vote rejected", "reason", validationErr.Message)
+		h.render(w, http.StatusUnprocessableEntity, "round-choices.html", "choice-error", RoundChoiceData{Error: validationErr.Message})
+		return
+	}
 	if err != nil {
-		slog.Error("Could not add choice " + choiceId)
+		slog.InfoContext(r.Context(), "Could not add choice", "choiceId", choiceId, "error", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	controlTime := time.Now()
-	for {
-		time.Sleep(time.Second)
-		if gamelogic.AllPlayersSelectedChoice(gameId.Value, roundId.Value) {
-			break
-		}
-		if time.Since(controlTime) > timeoutTime {
-			break
-		}
-	}
+	h.waitUntil(r.Context(), func() bool {
+		return gamelogic.AllPlayersSelectedChoice(gameId.Value, roundId.Value)
+	})
 
 	w.Header().Set("HX-Redirect", "/round-results")
 	w.Write(nil)
-	slog.Debug("Redirect to /round-results")
+	slog.DebugContext(r.Context(), "Redirect to /round-results")
 }
 
 type RoundResultsData struct {
-	Score []ScoreData
+	Question   string
+	Reveal     []gamelogic.RevealedAnswer
+	Score      gamelogic.Leaderboard
+	IsComplete bool
+	Awards     []gamelogic.Award // the highlights of the night, once the game is over
 }
 
-type ScoreData struct {
-	PlayerName string
-	Points     int
-}
-
-func RoundResultsHandler(w http.ResponseWriter, r *http.Request) {
-	slog.Debug("Entering RoundResults handler")
+func (h *Handlers) RoundResultsHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Entering RoundResults handler")
 
 	gameId, err := r.Cookie(gameIdCookie)
 	if err != nil {
@@ -187,23 +263,52 @@ func RoundResultsHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	score := gamelogic.GetScore(gameId.Value)
-	scoreData := []ScoreData{}
+	game, ok := gamelogic.GetGame(gameId.Value)
+	if !ok {
+		http.Error(w, "Game does not exist.", http.StatusBadRequest)
+		return
+	}
+
+	// Prefer the round the player took part in, the latest one may already be the next round.
+	round, err := gamelogic.GetLatestRound(gameId.Value)
+	if err != nil {
+		http.Error(w, "Could not get latest round", http.StatusInternalServerError)
+		return
+	}
+	if roundId, err := r.Cookie(roundIdCookie); err == nil {
+		for _, gr := range game.Rounds {
+			if gr.Id == roundId.Value {
+				round = gr
+			}
+		}
+	}
+
+	reveal, err := gamelogic.GetRoundReveal(gameId.Value, round.Id)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Could not get round reveal", "error", err)
+		http.Error(w, "Could not get round results", http.StatusInternalServerError)
+		return
+	}
 
-	for k, v := range score {
-		playerName := gamelogic.GetPlayer(k).Name
-		scoreData = append(scoreData, ScoreData{playerName, v})
+	leaderboard, err := gamelogic.GetLeaderboard(gameId.Value, round.Id)
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Could not get leaderboard", "error", err)
+		http.Error(w, "Could not get round results", http.StatusInternalServerError)
+		return
 	}
 
-	responseData := RoundResultsData{scoreData}
+	awards := []gamelogic.Award{}
+	if archived, ok := gamelogic.GetArchivedGame(game.Id); ok && game.IsComplete {
+		awards = archived.Awards
+	}
+	responseData := RoundResultsData{round.Question, reveal, leaderboard, game.IsComplete, awards}
 
-	tmpl := template.Must(template.ParseFiles("templates/round-results.html"))
-	tmpl.Execute(w, responseData)
-	slog.Debug("Serving round results template", "responseData", responseData)
+	h.renderPage(w, r, "round-results.html", responseData)
+	slog.DebugContext(r.Context(), "Serving round results template", "responseData", responseData)
 }
 
-func NewRoundReady(w http.ResponseWriter, r *http.Request) {
-	slog.Debug("Entering NewRoundReady handler")
+func (h *Handlers) NewRoundReady(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Entering NewRoundReady handler")
 
 	gameId, err := r.Cookie(gameIdCookie)
 	if err != nil {
@@ -217,20 +322,64 @@ func NewRoundReady(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	readyRound := ""
+	if round, err := gamelogic.GetLatestRound(gameId.Value); err == nil {
+		readyRound = round.Id
+	}
 	gamelogic.PlayerReady(gameId.Value, playerId.Value)
 
-	controlTime := time.Now()
-	for {
-		time.Sleep(time.Second)
-		if gamelogic.AllPlayersReady(gameId.Value) {
-			break
-		}
-		if time.Since(controlTime) > timeoutTime {
-			break
+	// The last player to get ready starts the next round
+	h.waitUntil(r.Context(), func() bool {
+		// An expired game never gets everyone ready
+		game, ok := gamelogic.GetGame(gameId.Value)
+		if !ok || game.IsComplete {
+			return true
 		}
+		round, err := gamelogic.GetLatestRound(gameId.Value)
+		return err == nil && round.Id != readyRound
+	})
+
+	if game, ok := gamelogic.GetGame(gameId.Value); ok && game.IsComplete {
+		w.Header().Set("HX-Redirect", "/round-results")
+		w.Write(nil)
+		slog.DebugContext(r.Context(), "Game complete, redirect to /round-results")
+		return
 	}
 
 	w.Header().Set("HX-Redirect", "/round-question")
 	w.Write(nil)
-	slog.Debug("Redirect to /round-question")
+	slog.DebugContext(r.Context(), "Redirect to /round-question")
+}
+
+// EndGameHandler ends the game from the results of a round, the players get
+// the final scores and the awards.
+func (h *Handlers) EndGameHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Entering EndGame handler")
+
+	gameId, err := r.Cookie(gameIdCookie)
+	if err != nil {
+		http.Error(w, "Could not find game id cookie.", http.StatusBadRequest)
+		return
+	}
+
+	playerId, err := r.Cookie(playerIdCookie)
+	if err != nil {
+		http.Error(w, "Could not find player id cookie.", http.StatusBadRequest)
+		return
+	}
+
+	err = gamelogic.EndGame(gameId.Value, playerId.Value)
+	if errors.Is(err, gamelogic.ErrRoundInProgress) {
+		http.Error(w, "The round is still being played.", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Could not end game", "error", err)
+		http.Error(w, "Could not end the game.", http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("HX-Redirect", "/round-results")
+	w.Write(nil)
+	slog.DebugContext(r.Context(), "Game ended, redirect to /round-results")
 }
@@ -1,39 +1,41 @@
 package handlers
 
 import (
-	"html/template"
+	"errors"
 	"log/slog"
 	"net/http"
 	"party-game/pkg/gamelogic"
+	"strconv"
 )
 
 const playerIdCookie string = "player-id"
 const gameIdCookie string = "game-id"
 const roundIdCookie string = "round-id"
 
-func HomePageHandler(w http.ResponseWriter, r *http.Request) {
-	slog.Debug("Entering Home handler")
-	tmpl := template.Must(template.ParseFiles("templates/home.html"))
-	tmpl.Execute(w, nil)
+type HomePageData struct {
+	GameModes   []gamelogic.GameMode
+	DefaultMode string
+	MaxBots     int
 }
 
-func CreatePlayerHandler(w http.ResponseWriter, r *http.Request) {
-	slog.Debug("Entering CreatePlayer handler")
+func (h *Handlers) HomePageHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Entering Home handler")
+	responseData := HomePageData{gamelogic.GameModes(), gamelogic.DefaultGameMode, gamelogic.MaxBotsPerGame}
+	h.renderPage(w, r, "home.html", responseData)
+}
 
-	if !IsPost(r) {
-		http.Error(w, "Error. Check server logs.", http.StatusBadRequest)
-		return
-	}
+func (h *Handlers) CreatePlayerHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Entering CreatePlayer handler")
 
 	if err := r.ParseForm(); err != nil {
-		slog.Error("Cannot parse form.", "error", err)
+		slog.ErrorContext(r.Context(), "Cannot parse form.", "error", err)
 		http.Error(w, "Error. Check server logs.", http.StatusBadRequest)
 		return
 	}
 
 	playerName := r.FormValue("player-name")
 	if playerName == "" {
-		slog.Error("Player name empty during create player")
+		slog.ErrorContext(r.Context(), "Player name empty during create player")
 		http.Error(w, "Player name empty. Try again.", http.StatusBadRequest)
 		return
 	}
@@ -53,12 +55,7 @@ func CreatePlayerHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("Player " + playerName + " created."))
 }
 
-func PlayerReadyHandler(w http.ResponseWriter, r *http.Request) {
-	if !IsPost(r) {
-		http.Error(w, "Error. Check server logs.", http.StatusBadRequest)
-		return
-	}
-
+func (h *Handlers) PlayerReadyHandler(w http.ResponseWriter, r *http.Request) {
 	playerId, err := r.Cookie(playerIdCookie)
 	if err != nil {
 		http.Error(w, "Player not identified. Make sure you have created one.", http.StatusBadRequest)
@@ -74,65 +71,99 @@ func PlayerReadyHandler(w http.ResponseWriter, r *http.Request) {
 	gamelogic.PlayerReady(gameId.Value, playerId.Value)
 }
 
-func CreateGameHandler(w http.ResponseWriter, r *http.Request) {
-	slog.Debug("Entering CreateGame handler")
-	if !IsPost(r) {
-		http.Error(w, "Error. Check server logs.", http.StatusBadRequest)
-		return
-	}
-
-	if err := r.ParseForm(); err != nil {
-		slog.Error("Cannot parse form.", "error", err)
+func (h *Handlers) CreateGameHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Entering CreateGame handler")
+	err := r.ParseForm()
+	if err != nil {
+		slog.ErrorContext(r.Context(), "Cannot parse form.", "error", err)
 		http.Error(w, "Error. Check server logs.", http.StatusBadRequest)
 		return
 	}
 
 	password := r.FormValue("game-password")
 	if password == "" {
-		slog.Error("Empty password in create game request", "Form", r.PostForm)
+		slog.ErrorContext(r.Context(), "Empty password in create game request")
 		http.Error(w, "Cannot create game with empty password", http.StatusBadRequest)
 		return
 	}
 
+	mode := r.FormValue("game-mode")
+	if _, ok := gamelogic.GetGameMode(mode); !ok {
+		slog.ErrorContext(r.Context(), "Unknown game mode in create game request", "mode", mode)
+		http.Error(w, "Unknown game mode.", http.StatusBadRequest)
+		return
+	}
+
+	botCount := 0
+	if botCountValue := r.FormValue("bot-count"); botCountValue != "" {
+		botCount, err = strconv.Atoi(botCountValue)
+		if err != nil {
+			http.Error(w, "Number of bots must be a number.", http.StatusBadRequest)
+			return
+		}
+	}
+	botStrategy := r.FormValue("bot-strategy")
+	if botStrategy == "" {
+		botStrategy = gamelogic.BotVoteRandom
+	}
+	// Checked before creating the game, a game without its bots would be left behind
+	if err := gamelogic.ValidateBots(h.config.Game.Rules(), botCount, botStrategy); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	player, err := r.Cookie(playerIdCookie)
 	if err != nil {
 		http.Error(w, "Player not identified. Make sure you have created one.", http.StatusBadRequest)
 		return
 	}
 
-	game, created := gamelogic.CreateGame(password, player.Value)
+	if h.passwordLockedOut(w, r) {
+		return
+	}
+	if !h.canCreateGame(r) {
+		http.Error(w, "Too many games running from your network, finish one first.", http.StatusTooManyRequests)
+		return
+	}
+	game, created := gamelogic.CreateGame(h.config.Game, password, player.Value, mode)
 	if !created {
+		h.failedPasswordGuess(r)
 		http.Error(w, "Could not create game. Probably a game with the same password is already running", http.StatusInternalServerError)
 		return
 	}
 
+	h.gameCreated(r, game.Id)
+
+	for i := 0; i < botCount; i++ {
+		// The game exists by now, the player goes in with the bots that made it
+		if _, err := gamelogic.AddBot(game.Id, botStrategy); err != nil {
+			slog.ErrorContext(r.Context(), "Could not add bot", "gameId", game.Id, "error", err)
+			break
+		}
+	}
+
 	w.Header().Set("HX-Redirect", "/round-question")
 	http.SetCookie(w, &http.Cookie{
 		Name:  gameIdCookie,
 		Value: game.Id,
 		Path:  "/",
 	})
-	slog.Debug("Redirecting to /round-question")
+	slog.DebugContext(r.Context(), "Redirecting to /round-question")
 	w.Write(nil)
 	return
 }
 
-func JoinGameHandler(w http.ResponseWriter, r *http.Request) {
-	slog.Debug("Entering JoinGame handler")
-	if !IsPost(r) {
-		http.Error(w, "Error. Check server logs.", http.StatusBadRequest)
-		return
-	}
-
+func (h *Handlers) JoinGameHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Entering JoinGame handler")
 	if err := r.ParseForm(); err != nil {
-		slog.Error("Cannot parse form.", "error", err)
+		slog.ErrorContext(r.Context(), "Cannot parse form.", "error", err)
 		http.Error(w, "Error. Check server logs.", http.StatusBadRequest)
 		return
 	}
 
 	password := r.FormValue("game-password")
 	if password == "" {
-		slog.Error("Empty password in create game request", "Form", r.PostForm)
+		slog.ErrorContext(r.Context(), "Empty password in create game request")
 		http.Error(w, "Cannot create game with empty password", http.StatusBadRequest)
 		return
 	}
@@ -143,7 +174,21 @@ func JoinGameHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	game, err := gamelogic.JoinGame(password, playerId.Value)
+	if h.passwordLockedOut(w, r) {
+		return
+	}
+	game, err := gamelogic.JoinGame(h.config.Game, password, playerId.Value)
+	if errors.Is(err, gamelogic.ErrGameNotFound) {
+		h.failedPasswordGuess(r)
+	}
+	if errors.Is(err, gamelogic.ErrGameFull) {
+		http.Error(w, "The game is full.", http.StatusConflict)
+		return
+	}
+	if errors.Is(err, gamelogic.ErrGameStarted) {
+		http.Error(w, "The game already started.", http.StatusConflict)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Could not join game. Check server logs", http.StatusBadRequest)
 		return
@@ -156,18 +201,37 @@ func JoinGameHandler(w http.ResponseWriter, r *http.Request) {
 		Path:  "/",
 	})
 
-	slog.Debug("Redirecting to /round-question")
+	slog.DebugContext(r.Context(), "Redirecting to /round-question")
 	w.Write(nil)
 	return
 }
 
-func IsPost(r *http.Request) bool {
-	switch r.Method {
-	case "POST":
-		return true
+// RequestAttrs returns the game and player a request is about, taken from the
+// path, the player header or the cookies, so the logs can be correlated.
+func RequestAttrs(r *http.Request) []slog.Attr {
+	attrs := []slog.Attr{}
+	gameId := r.PathValue("gameId")
+	if cookie, err := r.Cookie(gameIdCookie); gameId == "" && err == nil {
+		gameId = cookie.Value
+	}
+	if gameId != "" {
+		attrs = append(attrs, slog.String("game_id", gameId))
+	}
 
-	default:
-		slog.Debug("Request is not POST.", "RemoteAddress", r.RemoteAddr, "Method", r.Method, "URL", r.URL)
+	if playerId := requestPlayerId(r); playerId != "" {
+		attrs = append(attrs, slog.String("player_id", playerId))
+	}
+	return attrs
+}
+
+// requestPlayerId returns the player id from the X-Player-Id header of the
+// API or the player cookie of the pages, empty when there is neither.
+func requestPlayerId(r *http.Request) string {
+	if playerId := r.Header.Get(playerIdHeader); playerId != "" {
+		return playerId
+	}
+	if cookie, err := r.Cookie(playerIdCookie); err == nil {
+		return cookie.Value
 	}
-	return false
+	return ""
 }
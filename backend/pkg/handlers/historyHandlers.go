@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"log/slog"
+	"net/http"
+	"party-game/pkg/gamelogic"
+	"time"
+)
+
+type APIArchivedGame struct {
+	Id         string             `json:"id"`
+	Mode       string             `json:"mode"`
+	StartedAt  time.Time          `json:"startedAt"`
+	FinishedAt time.Time          `json:"finishedAt"`
+	Rounds     []APIArchivedRound `json:"rounds"`
+	Scores     []APIScore         `json:"scores"` // the final standings, with every player
+	Awards     []APIAward         `json:"awards"`
+}
+
+type APIArchivedRound struct {
+	Question  string      `json:"question"`
+	SubjectId string      `json:"subjectId"` // the player the question was about
+	Answers   []APIAnswer `json:"answers"`   // best answers first
+}
+
+type APIAward struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	PlayerId    string `json:"playerId"`
+	PlayerName  string `json:"playerName"`
+	Detail      string `json:"detail"`
+}
+
+type HistoryData struct {
+	Games []gamelogic.ArchivedGame
+}
+
+type HistoryGameData struct {
+	Game gamelogic.ArchivedGame
+}
+
+// HistoryHandler lists the finished games of the player.
+func (h *Handlers) HistoryHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Entering History handler")
+	games := []gamelogic.ArchivedGame{}
+	if playerId := requestPlayerId(r); playerId != "" {
+		games = gamelogic.HistoryOf(playerId)
+	}
+	h.renderPage(w, r, "history.html", HistoryData{games})
+}
+
+// HistoryGameHandler replays a finished game round by round.
+func (h *Handlers) HistoryGameHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Entering HistoryGame handler")
+	archived, ok := archivedGameOf(r)
+	if !ok {
+		http.Error(w, "There is no history of this game for you.", http.StatusNotFound)
+		return
+	}
+	h.renderPage(w, r, "history-game.html", HistoryGameData{archived})
+}
+
+// APIHistoryHandler exports a finished game with every round, answer and vote.
+func (h *Handlers) APIHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	slog.DebugContext(r.Context(), "Entering APIHistory handler")
+	if requestPlayerId(r) == "" {
+		writeAPIError(w, http.StatusUnauthorized, "Player not identified. Send your player id in the "+playerIdHeader+" header.")
+		return
+	}
+	archived, ok := archivedGameOf(r)
+	if !ok {
+		writeAPIError(w, http.StatusNotFound, "There is no history of this game for you.")
+		return
+	}
+	writeJSON(w, http.StatusOK, toAPIArchivedGame(archived))
+}
+
+// archivedGameOf returns the archived game in the path when the requesting
+// player took part in it. The player may be long gone from the running
+// games, so only the history is asked.
+func archivedGameOf(r *http.Request) (gamelogic.ArchivedGame, bool) {
+	archived, ok := gamelogic.GetArchivedGame(r.PathValue("gameId"))
+	if !ok || !archived.HasPlayer(requestPlayerId(r)) {
+		return gamelogic.ArchivedGame{}, false
+	}
+	return archived, true
+}
+
+func toAPIArchivedGame(archived gamelogic.ArchivedGame) APIArchivedGame {
+	response := APIArchivedGame{
+		Id:         archived.Id,
+		Mode:       archived.Mode,
+		StartedAt:  archived.StartedAt,
+		FinishedAt: archived.FinishedAt,
+		Rounds:     []APIArchivedRound{},
+		Scores:     []APIScore{},
+		Awards:     []APIAward{},
+	}
+	for _, round := range archived.Rounds {
+		answers := []APIAnswer{}
+		for _, a := range round.Answers {
+			answers = append(answers, APIAnswer{a.Id, a.Text, a.AuthorId, a.AuthorName, a.VoterIds, a.Points})
+		}
+		response.Rounds = append(response.Rounds, APIArchivedRound{round.Question, round.SubjectId, answers})
+	}
+	for _, e := range archived.Leaderboard {
+		response.Scores = append(response.Scores, APIScore{e.PlayerId, e.PlayerName, e.Points, e.Rank, e.Delta, e.RankChange})
+	}
+	for _, a := range archived.Awards {
+		response.Awards = append(response.Awards, APIAward{a.Title, a.Description, a.PlayerId, a.PlayerName, a.Detail})
+	}
+	return response
+}
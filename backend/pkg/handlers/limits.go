@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"log/slog"
+	"math"
+	"net/http"
+	"party-game/pkg/gamelogic"
+	"party-game/pkg/ratelimit"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The guests of a party usually share the IP of the venue's router, so the
+// per IP limits leave room for a whole party arriving at once and wrong
+// passwords only lock out the player who typed them.
+type LimitConfig struct {
+	CreatePlayer ratelimit.Rate // per client IP
+	Join         ratelimit.Rate // joining and creating games, per client IP and per player
+	Submit       ratelimit.Rate // answers and votes per player
+	// A player who sends this many wrong game passwords within the lockout
+	// duration cannot join any game from their IP until the lockout has passed
+	BadPasswordAttempts int
+	BadPasswordLockout  time.Duration
+	MaxGamesPerIP       int // running games created from one IP, 0 is unlimited
+}
+
+func DefaultLimitConfig() LimitConfig {
+	return LimitConfig{
+		CreatePlayer:        ratelimit.Rate{PerMinute: 30, Burst: 20},
+		Join:                ratelimit.Rate{PerMinute: 60, Burst: 20},
+		Submit:              ratelimit.Rate{PerMinute: 60, Burst: 10},
+		BadPasswordAttempts: 5,
+		BadPasswordLockout:  15 * time.Minute,
+		MaxGamesPerIP:       20,
+	}
+}
+
+type limits struct {
+	config       LimitConfig
+	createPlayer *ratelimit.Limiter
+	joinByIP     *ratelimit.Limiter
+	joinByPlayer *ratelimit.Limiter
+	submit       *ratelimit.Limiter
+	badPasswords *ratelimit.Lockout
+
+	gamesLock sync.Mutex
+	gamesByIP map[string][]string // ids of the games created from each IP
+}
+
+func newLimits(config LimitConfig) *limits {
+	return &limits{
+		config:       config,
+		createPlayer: ratelimit.NewLimiter(config.CreatePlayer),
+		joinByIP:     ratelimit.NewLimiter(config.Join),
+		joinByPlayer: ratelimit.NewLimiter(config.Join),
+		submit:       ratelimit.NewLimiter(config.Submit),
+		badPasswords: ratelimit.NewLockout(config.BadPasswordAttempts, config.BadPasswordLockout),
+		gamesByIP:    map[string][]string{},
+	}
+}
+
+func (h *Handlers) limitCreatePlayer(next http.HandlerFunc) http.HandlerFunc {
+	return rateLimit(h.limits.createPlayer, ratelimit.ClientIP, next)
+}
+
+func (h *Handlers) limitJoin(next http.HandlerFunc) http.HandlerFunc {
+	return rateLimit(h.limits.joinByIP, ratelimit.ClientIP, rateLimit(h.limits.joinByPlayer, playerKey, next))
+}
+
+func (h *Handlers) limitSubmit(next http.HandlerFunc) http.HandlerFunc {
+	return rateLimit(h.limits.submit, playerKey, next)
+}
+
+func rateLimit(limiter *ratelimit.Limiter, key func(*http.Request) string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if ok, wait := limiter.Allow(key(r)); !ok {
+			tooManyRequests(w, r, wait)
+			return
+		}
+		next(w, r)
+	}
+}
+
+// playerKey identifies the session of a request by its player, requests
+// without one share the bucket of their IP.
+func playerKey(r *http.Request) string {
+	if playerId, ok := knownPlayer(r); ok {
+		return playerId
+	}
+	return "ip:" + ratelimit.ClientIP(r)
+}
+
+// knownPlayer returns the player id the request was sent with, as long as the
+// player exists. A made up id gets no bucket of its own.
+func knownPlayer(r *http.Request) (string, bool) {
+	playerId := r.Header.Get(playerIdHeader)
+	if cookie, err := r.Cookie(playerIdCookie); err == nil && playerId == "" {
+		playerId = cookie.Value
+	}
+	if playerId == "" || gamelogic.GetPlayer(playerId).Id == "" {
+		return "", false
+	}
+	return playerId, true
+}
+
+func tooManyRequests(w http.ResponseWriter, r *http.Request, wait time.Duration) {
+	slog.WarnContext(r.Context(), "Rate limited request", "path", r.URL.Path, "ip", ratelimit.ClientIP(r), "retryAfter", wait)
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	message := "Too many requests, try again in " + wait.Round(time.Second).String() + "."
+	if strings.HasPrefix(r.URL.Path, apiPrefix) {
+		writeAPIError(w, http.StatusTooManyRequests, message)
+		return
+	}
+	http.Error(w, message, http.StatusTooManyRequests)
+}
+
+// lockoutKey is the player of the request at its IP. A guest mistyping the
+// password does not lock out the rest of the party behind the same router,
+// and a script getting around the lockout with new players runs into the
+// create player limit of the IP. Requests without an existing player are
+// counted for their IP.
+func lockoutKey(r *http.Request) string {
+	if playerId, ok := knownPlayer(r); ok {
+		return ratelimit.ClientIP(r) + " " + playerId
+	}
+	return ratelimit.ClientIP(r)
+}
+
+// passwordLockedOut writes an error when the player of the request sent too
+// many wrong passwords.
+func (h *Handlers) passwordLockedOut(w http.ResponseWriter, r *http.Request) bool {
+	locked, wait := h.limits.badPasswords.Locked(lockoutKey(r))
+	if locked {
+		tooManyRequests(w, r, wait)
+	}
+	return locked
+}
+
+// failedPasswordGuess counts towards the lockout. Besides joining with a
+// wrong password, creating a game with a taken one counts too, otherwise
+// creating games would tell which passwords are in use.
+func (h *Handlers) failedPasswordGuess(r *http.Request) {
+	if h.limits.badPasswords.Fail(lockoutKey(r)) {
+		slog.WarnContext(r.Context(), "Locked out player after repeated wrong game passwords", "ip", ratelimit.ClientIP(r),
+			"lockout", h.limits.config.BadPasswordLockout)
+	}
+}
+
+// canCreateGame reports whether the IP of the request may create another
+// game, finished and deleted games do not count.
+func (h *Handlers) canCreateGame(r *http.Request) bool {
+	if h.limits.config.MaxGamesPerIP <= 0 {
+		return true
+	}
+	h.limits.gamesLock.Lock()
+	defer h.limits.gamesLock.Unlock()
+	for ip, gameIds := range h.limits.gamesByIP {
+		running := []string{}
+		for _, gameId := range gameIds {
+			if game, ok := gamelogic.GetGame(gameId); ok && !game.IsComplete {
+				running = append(running, gameId)
+			}
+		}
+		if len(running) == 0 {
+			delete(h.limits.gamesByIP, ip)
+		} else {
+			h.limits.gamesByIP[ip] = running
+		}
+	}
+	return len(h.limits.gamesByIP[ratelimit.ClientIP(r)]) < h.limits.config.MaxGamesPerIP
+}
+
+func (h *Handlers) gameCreated(r *http.Request, gameId string) {
+	ip := ratelimit.ClientIP(r)
+	h.limits.gamesLock.Lock()
+	defer h.limits.gamesLock.Unlock()
+	h.limits.gamesByIP[ip] = append(h.limits.gamesByIP[ip], gameId)
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"party-game/pkg/gamelogic"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// newTestPlayers creates a player for each name, prefixed with the name of
+// the test as player names are unique.
+func newTestPlayers(t *testing.T, names ...string) map[string]string {
+	t.Helper()
+	ids := map[string]string{}
+	for _, name := range names {
+		player, ok := gamelogic.CreatePlayer(t.Name() + " " + name)
+		if !ok {
+			t.Fatalf("could not create player %s", name)
+		}
+		ids[name] = player.Id
+	}
+	return ids
+}
+
+func guessRequest(ip string, playerId string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/join-game", nil)
+	r.RemoteAddr = ip + ":40000"
+	if playerId != "" {
+		r.AddCookie(&http.Cookie{Name: playerIdCookie, Value: playerId})
+	}
+	return r
+}
+
+// TestPasswordLockoutIsPerPlayer plays a party behind one router where a
+// guest keeps mistyping the password.
+func TestPasswordLockoutIsPerPlayer(t *testing.T) {
+	h := &Handlers{limits: newLimits(LimitConfig{BadPasswordAttempts: 3, BadPasswordLockout: time.Minute})}
+	ids := newTestPlayers(t, "ann", "bob")
+	for i := 0; i < 3; i++ {
+		h.failedPasswordGuess(guessRequest("10.0.0.1", ids["ann"]))
+	}
+
+	tests := []struct {
+		name     string
+		ip       string
+		playerId string
+		locked   bool
+	}{
+		{name: "the guest who mistyped", ip: "10.0.0.1", playerId: ids["ann"], locked: true},
+		{name: "another guest behind the same router", ip: "10.0.0.1", playerId: ids["bob"], locked: false},
+		{name: "the same player from another network", ip: "10.0.0.2", playerId: ids["ann"], locked: false},
+		{name: "no player yet", ip: "10.0.0.1", locked: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			if locked := h.passwordLockedOut(w, guessRequest(tt.ip, tt.playerId)); locked != tt.locked {
+				t.Fatalf("passwordLockedOut() = %v, want %v", locked, tt.locked)
+			}
+			if tt.locked && w.Code != http.StatusTooManyRequests {
+				t.Errorf("status = %d, want %d", w.Code, http.StatusTooManyRequests)
+			}
+		})
+	}
+}
+
+// TestPasswordLockoutIgnoresForgedPlayers guesses with a made up player id
+// each time, the guesses count for the IP.
+func TestPasswordLockoutIgnoresForgedPlayers(t *testing.T) {
+	h := &Handlers{limits: newLimits(LimitConfig{BadPasswordAttempts: 3, BadPasswordLockout: time.Minute})}
+	ids := newTestPlayers(t, "ann")
+	for i := 0; i < 3; i++ {
+		h.failedPasswordGuess(guessRequest("10.0.0.1", "forged-"+strconv.Itoa(i)))
+	}
+
+	tests := []struct {
+		name     string
+		ip       string
+		playerId string
+		locked   bool
+	}{
+		{name: "the next forged id", ip: "10.0.0.1", playerId: "forged-3", locked: true},
+		{name: "no player", ip: "10.0.0.1", locked: true},
+		{name: "a real guest behind the same router", ip: "10.0.0.1", playerId: ids["ann"], locked: false},
+		{name: "a forged id from another network", ip: "10.0.0.2", playerId: "forged-4", locked: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			if locked := h.passwordLockedOut(w, guessRequest(tt.ip, tt.playerId)); locked != tt.locked {
+				t.Fatalf("passwordLockedOut() = %v, want %v", locked, tt.locked)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package handlers
+
+import (
+	"net/http"
+	"party-game/pkg/gamelogic"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// apiRoute describes one endpoint of the JSON API. The same table registers
+// the routes on the mux and generates the OpenAPI document, so the two cannot
+// drift apart.
+type apiRoute struct {
+	Method      string
+	Path        string // relative to apiPrefix, with {name} path parameters
+	Summary     string
+	Handler     http.HandlerFunc
+	Request     any // zero value of the request body type, nil when there is no body
+	Response    any // zero value of the response body type, nil when there is no body
+	Status      int
+	ContentType string // response content type, defaults to application/json
+	Public      bool   // true when no player id is needed
+}
+
+func (h *Handlers) apiRoutes() []apiRoute {
+	return []apiRoute{
+		{Method: "POST", Path: "/players", Summary: "Create a player", Handler: h.limitCreatePlayer(h.APICreatePlayerHandler),
+			Request: CreatePlayerRequest{}, Response: APIPlayer{}, Status: http.StatusCreated, Public: true},
+		{Method: "GET", Path: "/players/{playerId}", Summary: "Get a player", Handler: h.APIGetPlayerHandler,
+			Response: APIPlayer{}, Status: http.StatusOK},
+		{Method: "POST", Path: "/games", Summary: "Create a game and join it", Handler: h.limitJoin(h.APICreateGameHandler),
+			Request: CreateGameRequest{}, Response: APIGame{}, Status: http.StatusCreated},
+		{Method: "POST", Path: "/games/join", Summary: "Join a game by its password", Handler: h.limitJoin(h.APIJoinGameHandler),
+			Request: JoinGameRequest{}, Response: APIGame{}, Status: http.StatusOK},
+		{Method: "GET", Path: "/games/{gameId}", Summary: "Get a game", Handler: h.APIGetGameHandler,
+			Response: APIGame{}, Status: http.StatusOK},
+		{Method: "GET", Path: "/games/{gameId}/rounds", Summary: "List the rounds of a game", Handler: h.APIListRoundsHandler,
+			Response: []APIRound{}, Status: http.StatusOK},
+		{Method: "GET", Path: "/games/{gameId}/rounds/current", Summary: "Get the round being played", Handler: h.APICurrentRoundHandler,
+			Response: APIRound{}, Status: http.StatusOK},
+		{Method: "GET", Path: "/games/{gameId}/rounds/{roundId}", Summary: "Get a round", Handler: h.APIGetRoundHandler,
+			Response: APIRound{}, Status: http.StatusOK},
+		{Method: "GET", Path: "/games/{gameId}/rounds/{roundId}/answers", Summary: "List the answers of a round once everybody voted", Handler: h.APIListAnswersHandler,
+			Response: []APIAnswer{}, Status: http.StatusOK},
+		{Method: "POST", Path: "/games/{gameId}/rounds/{roundId}/answers", Summary: "Submit or replace your answer", Handler: h.limitSubmit(h.APISubmitAnswerHandler),
+			Request: SubmitAnswerRequest{}, Status: http.StatusNoContent},
+		{Method: "GET", Path: "/games/{gameId}/rounds/{roundId}/votes", Summary: "List the votes of a round once everybody voted", Handler: h.APIListVotesHandler,
+			Response: []APIVote{}, Status: http.StatusOK},
+		{Method: "POST", Path: "/games/{gameId}/rounds/{roundId}/votes", Summary: "Vote for an answer", Handler: h.limitSubmit(h.APISubmitVoteHandler),
+			Request: SubmitVoteRequest{}, Status: http.StatusNoContent},
+		{Method: "POST", Path: "/games/{gameId}/ready", Summary: "Mark yourself ready for the next round", Handler: h.APIReadyHandler,
+			Status: http.StatusNoContent},
+		{Method: "POST", Path: "/games/{gameId}/end", Summary: "End the game after the results of a round, it moves to the history", Handler: h.APIEndGameHandler,
+			Response: APIGame{}, Status: http.StatusOK},
+		{Method: "POST", Path: "/heartbeat", Summary: "Tell the server you are still there, without it the rounds stop waiting for you after a while", Handler: h.APIHeartbeatHandler,
+			Status: http.StatusNoContent},
+		{Method: "GET", Path: "/games/{gameId}/scores", Summary: "Get the leaderboard, optionally after the round in the roundId query parameter", Handler: h.APIScoresHandler,
+			Response: []APIScore{}, Status: http.StatusOK},
+		{Method: "GET", Path: "/games/{gameId}/history", Summary: "Export a finished game you played with every round, answer and vote", Handler: h.APIHistoryHandler,
+			Response: APIArchivedGame{}, Status: http.StatusOK},
+		{Method: "GET", Path: "/games/{gameId}/events", Summary: "Stream the game events as server-sent events", Handler: h.APIEventsHandler,
+			Response: gamelogic.Event{}, Status: http.StatusOK, ContentType: "text/event-stream"},
+	}
+}
+
+var pathParameterPattern = regexp.MustCompile(`\{([^}]+)\}`)
+
+// OpenAPIHandler serves the OpenAPI 3 document of the JSON API.
+func (h *Handlers) OpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, h.OpenAPIDocument())
+}
+
+// OpenAPIDocument builds the OpenAPI 3 description of the API routes from the
+// Go request and response types.
+func (h *Handlers) OpenAPIDocument() map[string]any {
+	schemas := map[string]any{}
+	paths := map[string]any{}
+
+	errorResponse := map[string]any{
+		"description": "Error",
+		"content":     map[string]any{"application/json": map[string]any{"schema": schemaOf(reflect.TypeOf(APIError{}), schemas)}},
+	}
+
+	for _, route := range h.apiRoutes() {
+		operation := map[string]any{
+			"summary":     route.Summary,
+			"operationId": operationId(route),
+		}
+
+		parameters := []any{}
+		for _, match := range pathParameterPattern.FindAllStringSubmatch(route.Path, -1) {
+			parameters = append(parameters, map[string]any{
+				"name": match[1], "in": "path", "required": true, "schema": map[string]any{"type": "string"},
+			})
+		}
+		if len(parameters) > 0 {
+			operation["parameters"] = parameters
+		}
+		if !route.Public {
+			operation["security"] = []any{map[string]any{"playerId": []string{}}}
+		}
+
+		if route.Request != nil {
+			operation["requestBody"] = map[string]any{
+				"required": true,
+				"content":  map[string]any{"application/json": map[string]any{"schema": schemaOf(reflect.TypeOf(route.Request), schemas)}},
+			}
+		}
+
+		success := map[string]any{"description": http.StatusText(route.Status)}
+		if route.Response != nil {
+			contentType := route.ContentType
+			if contentType == "" {
+				contentType = "application/json"
+			}
+			success["content"] = map[string]any{contentType: map[string]any{"schema": schemaOf(reflect.TypeOf(route.Response), schemas)}}
+		}
+		operation["responses"] = map[string]any{
+			strconv.Itoa(route.Status): success,
+			"default":                  errorResponse,
+		}
+
+		path, ok := paths[apiPrefix+route.Path].(map[string]any)
+		if !ok {
+			path = map[string]any{}
+			paths[apiPrefix+route.Path] = path
+		}
+		path[strings.ToLower(route.Method)] = operation
+	}
+
+	return map[string]any{
+		"openapi": "3.0.3",
+		"info": map[string]any{
+			"title":   "Party Game API",
+			"version": "1",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": schemas,
+			"securitySchemes": map[string]any{
+				"playerId": map[string]any{"type": "apiKey", "in": "header", "name": playerIdHeader},
+			},
+		},
+	}
+}
+
+// operationId turns "POST /games/{gameId}/ready" into "postGamesGameIdReady".
+func operationId(route apiRoute) string {
+	id := strings.ToLower(route.Method)
+	for _, part := range strings.FieldsFunc(route.Path, func(r rune) bool { return r == '/' || r == '{' || r == '}' }) {
+		id += strings.ToUpper(part[:1]) + part[1:]
+	}
+	return id
+}
+
+// schemaOf returns the schema of t, adding named structs to schemas and
+// referencing them.
+func schemaOf(t reflect.Type, schemas map[string]any) map[string]any {
+	if t == reflect.TypeOf(time.Time{}) {
+		return map[string]any{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.Pointer:
+		return schemaOf(t.Elem(), schemas)
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": schemaOf(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaOf(t.Elem(), schemas)}
+	case reflect.Struct:
+		if _, ok := schemas[t.Name()]; !ok {
+			// Reserve the name first so recursive types terminate
+			schemas[t.Name()] = map[string]any{}
+			properties := map[string]any{}
+			required := []string{}
+			for i := 0; i < t.NumField(); i++ {
+				field := t.Field(i)
+				if !field.IsExported() {
+					continue
+				}
+				name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+				if name == "-" {
+					continue
+				}
+				if name == "" {
+					name = field.Name
+				}
+				properties[name] = schemaOf(field.Type, schemas)
+				if !strings.Contains(options, "omitempty") {
+					required = append(required, name)
+				}
+			}
+			schemas[t.Name()] = map[string]any{"type": "object", "properties": properties, "required": required}
+		}
+		return map[string]any{"$ref": "#/components/schemas/" + t.Name()}
+	default:
+		return map[string]any{}
+	}
+}
@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"net/http"
+	"party-game/pkg/gamelogic"
+)
+
+// trackPresence tells the game the player sending the request is still
+// there, from the X-Player-Id header of the API or the player cookie of the pages.
+func trackPresence(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if playerId := requestPlayerId(r); playerId != "" {
+			gamelogic.Seen(playerId)
+		}
+		next(w, r)
+	}
+}
+
+// HeartbeatHandler answers the heartbeat the game pages send every few
+// seconds, so players whose phone died are noticed.
+func (h *Handlers) HeartbeatHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusNoContent)
+}
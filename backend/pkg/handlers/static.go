@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"party-game/static"
+	"strings"
+	"time"
+)
+
+const staticPrefix string = "/static/"
+
+type asset struct {
+	URL       string
+	Integrity string
+	etag      string
+	data      []byte
+}
+
+// assetRegistry maps a file name to what the pages need to load it.
+type assetRegistry map[string]asset
+
+// newAssetRegistry hashes every embedded file once. The hash is the SRI value
+// and versions the URL, so the files can be cached forever. The pages never
+// load anything from the internet, so a library that is not vendored, or not
+// the pinned version, stops the server from starting.
+func newAssetRegistry(fsys fs.FS) (assetRegistry, error) {
+	assets := assetRegistry{}
+	err := fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		data, err := fs.ReadFile(fsys, path)
+		if err != nil {
+			return err
+		}
+		sum := sha512.Sum384(data)
+		version := hex.EncodeToString(sum[:6])
+		assets[path] = asset{
+			URL:       staticPrefix + path + "?v=" + version,
+			Integrity: "sha384-" + base64.StdEncoding.EncodeToString(sum[:]),
+			etag:      `"` + version + `"`,
+			data:      data,
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, lib := range static.Libraries {
+		asset, ok := assets[lib.File]
+		if !ok {
+			return nil, fmt.Errorf("library %s is not vendored, run go generate ./static and rebuild", lib.File)
+		}
+		if asset.Integrity != lib.Integrity {
+			return nil, fmt.Errorf("vendored library %s has integrity %s, want %s", lib.File, asset.Integrity, lib.Integrity)
+		}
+	}
+	return assets, nil
+}
+
+// lookup is the asset template function. An unknown file fails the render
+// instead of producing a broken link.
+func (a assetRegistry) lookup(name string) (asset, error) {
+	if asset, ok := a[name]; ok {
+		return asset, nil
+	}
+	return asset{}, fs.ErrNotExist
+}
+
+func (h *Handlers) StaticHandler(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, staticPrefix)
+	asset, ok := h.assets[name]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("ETag", asset.etag)
+	if r.URL.Query().Get("v") != "" {
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	} else {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(asset.data))
+}
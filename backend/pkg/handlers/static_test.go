@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"party-game/static"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestNewAssetRegistryNeedsVendoredLibraries(t *testing.T) {
+	vendored := func() fstest.MapFS {
+		fsys := fstest.MapFS{"game.css": {Data: []byte("body {}")}}
+		for _, lib := range static.Libraries {
+			fsys[lib.File] = &fstest.MapFile{Data: []byte("not the pinned release")}
+		}
+		return fsys
+	}
+	missing := vendored()
+	delete(missing, static.Libraries[0].File)
+
+	tests := []struct {
+		name    string
+		fsys    fstest.MapFS
+		wantErr string
+	}{
+		{name: "missing library", fsys: missing, wantErr: "not vendored"},
+		{name: "wrong version", fsys: vendored(), wantErr: "has integrity"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newAssetRegistry(tt.fsys)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("newAssetRegistry() = %v, want an error mentioning %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestEmbeddedAssets checks the files that are built into the server, which
+// refuses to start when a library is missing or not the pinned release.
+func TestEmbeddedAssets(t *testing.T) {
+	assets, err := newAssetRegistry(static.FS)
+	if err != nil {
+		t.Fatalf("newAssetRegistry(static.FS) = %v", err)
+	}
+	for _, lib := range static.Libraries {
+		if _, err := assets.lookup(lib.File); err != nil {
+			t.Errorf("lookup(%q) = %v", lib.File, err)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+)
+
+const layoutFile string = "layout.html"
+const layoutTemplate string = "layout"
+
+// templateRegistry holds every page parsed together with the layout. Pages
+// are parsed once, unless reload is set, in which case they are parsed again
+// on every render so template edits show up without restarting the server.
+type templateRegistry struct {
+	fsys   fs.FS
+	reload bool
+	funcs  template.FuncMap
+	pages  map[string]*template.Template
+}
+
+func newTemplateRegistry(fsys fs.FS, reload bool, funcs template.FuncMap) (*templateRegistry, error) {
+	pages, err := parseTemplates(fsys, funcs)
+	if err != nil {
+		return nil, err
+	}
+	return &templateRegistry{fsys, reload, funcs, pages}, nil
+}
+
+func parseTemplates(fsys fs.FS, funcs template.FuncMap) (map[string]*template.Template, error) {
+	files, err := fs.Glob(fsys, "*.html")
+	if err != nil {
+		return nil, err
+	}
+
+	pages := map[string]*template.Template{}
+	for _, file := range files {
+		if file == layoutFile {
+			continue
+		}
+		tmpl, err := template.New(file).Funcs(funcs).ParseFS(fsys, layoutFile, file)
+		if err != nil {
+			return nil, fmt.Errorf("parsing template %s: %w", file, err)
+		}
+		pages[file] = tmpl
+	}
+	return pages, nil
+}
+
+// execute renders the named template of page into w.
+func (t *templateRegistry) execute(w io.Writer, page string, name string, data any) error {
+	pages := t.pages
+	if t.reload {
+		var err error
+		if pages, err = parseTemplates(t.fsys, t.funcs); err != nil {
+			return err
+		}
+	}
+
+	tmpl, ok := pages[page]
+	if !ok {
+		return fmt.Errorf("unknown template %s", page)
+	}
+	return tmpl.ExecuteTemplate(w, name, data)
+}
+
+// check fails when the pages cannot be rendered, when reloading that means
+// the templates on disk do not parse.
+func (t *templateRegistry) check() error {
+	pages := t.pages
+	if t.reload {
+		var err error
+		if pages, err = parseTemplates(t.fsys, t.funcs); err != nil {
+			return err
+		}
+	}
+	if len(pages) == 0 {
+		return errors.New("no templates loaded")
+	}
+	return nil
+}
+
+// pageData is what the layout gets, the page itself sees only Content.
+type pageData struct {
+	CSRFToken string
+	Content   any
+}
+
+// renderPage writes page inside the layout.
+func (h *Handlers) renderPage(w http.ResponseWriter, r *http.Request, page string, data any) {
+	h.render(w, http.StatusOK, page, layoutTemplate, pageData{csrfToken(w, r), data})
+}
+
+// render writes a single template of page, e.g. a fragment htmx swaps in,
+// with the given status. The output is buffered so a failing template ends
+// in an error response instead of a half written page.
+func (h *Handlers) render(w http.ResponseWriter, status int, page string, name string, data any) {
+	var buf bytes.Buffer
+	if err := h.templates.execute(&buf, page, name, data); err != nil {
+		slog.Error("Could not render template", "page", page, "template", name, "error", err)
+		http.Error(w, "Error. Check server logs.", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	buf.WriteTo(w)
+}
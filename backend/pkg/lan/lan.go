@@ -0,0 +1,117 @@
+// Package lan lets the server host a party on a local network without
+// internet: it picks the LAN address, advertises it with mDNS and prints a
+// QR code of the join URL for the phones.
+package lan
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+
+	"github.com/hashicorp/mdns"
+	"github.com/mdp/qrterminal/v3"
+)
+
+const serviceType string = "_http._tcp"
+
+type Config struct {
+	Enabled   bool
+	Interface string // empty picks the first interface that is up and has a private IPv4 address
+	Hostname  string // advertised as <Hostname>.local
+	Instance  string // the name service browsers show
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Enabled:   false,
+		Interface: "",
+		Hostname:  "partygame",
+		Instance:  "Party Game",
+	}
+}
+
+type Host struct {
+	config Config
+	iface  *net.Interface
+	IP     net.IP
+	mdns   *mdns.Server
+}
+
+// Find picks the interface and the address to serve on.
+func Find(config Config) (*Host, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, iface := range ifaces {
+		if config.Interface != "" && iface.Name != config.Interface {
+			continue
+		}
+		if iface.Flags&net.FlagUp == 0 || iface.Flags&net.FlagLoopback != 0 {
+			continue
+		}
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range addrs {
+			ipNet, ok := addr.(*net.IPNet)
+			if !ok {
+				continue
+			}
+			// A named interface is trusted to be the party network even when
+			// its address is not in a private range
+			if ip := ipNet.IP.To4(); ip != nil && (ip.IsPrivate() || config.Interface != "") {
+				return &Host{config: config, iface: &iface, IP: ip}, nil
+			}
+		}
+	}
+
+	if config.Interface != "" {
+		return nil, fmt.Errorf("interface %s is not up or has no IPv4 address", config.Interface)
+	}
+	return nil, errors.New("no network interface with a private IPv4 address, connect to the party network first")
+}
+
+// Advertise answers mDNS queries for <Hostname>.local and the web service on
+// port until Shutdown is called.
+func (h *Host) Advertise(port int) error {
+	service, err := mdns.NewMDNSService(h.config.Instance, serviceType, "", h.config.Hostname+".local.", port, []net.IP{h.IP}, []string{"path=/"})
+	if err != nil {
+		return err
+	}
+	h.mdns, err = mdns.NewServer(&mdns.Config{
+		Zone:   service,
+		Iface:  h.iface,
+		Logger: slog.NewLogLogger(slog.Default().Handler(), slog.LevelDebug),
+	})
+	if err != nil {
+		return err
+	}
+	slog.Info("Advertising with mDNS", "interface", h.iface.Name, "hostname", h.config.Hostname+".local", "ip", h.IP)
+	return nil
+}
+
+func (h *Host) Shutdown() error {
+	if h.mdns == nil {
+		return nil
+	}
+	return h.mdns.Shutdown()
+}
+
+// PrintJoinInfo writes the join URLs and a QR code of the one with the IP
+// address, which works on phones that cannot resolve .local names.
+func (h *Host) PrintJoinInfo(w io.Writer, scheme string, port int) {
+	ipURL := scheme + "://" + net.JoinHostPort(h.IP.String(), strconv.Itoa(port)) + "/"
+	nameURL := scheme + "://" + net.JoinHostPort(h.config.Hostname+".local", strconv.Itoa(port)) + "/"
+
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Join the party at "+ipURL+" or "+nameURL)
+	fmt.Fprintln(w)
+	qrterminal.GenerateHalfBlock(ipURL, qrterminal.L, w)
+	fmt.Fprintln(w)
+}
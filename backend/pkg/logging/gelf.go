@@ -0,0 +1,274 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/Graylog2/go-gelf/gelf"
+)
+
+const gelfFacility string = "party-game"
+const gelfQueueSize int = 1024
+const gelfDialTimeout time.Duration = 2 * time.Second
+const gelfWriteTimeout time.Duration = 2 * time.Second
+const gelfErrorInterval time.Duration = 30 * time.Second
+
+// gelfCloseTimeout is how long Close keeps sending the queue on shutdown.
+var gelfCloseTimeout time.Duration = 3 * time.Second
+
+// gelfHandler turns records into GELF messages. Attributes become additional
+// fields, with groups joined by underscores.
+type gelfHandler struct {
+	sender *gelfSender
+	level  slog.Level
+	host   string
+	fields map[string]any
+	prefix string
+}
+
+func newGELFHandler(addr string, protocol string, level slog.Level) (*gelfHandler, *gelfSender, error) {
+	var transport gelfTransport
+	if protocol == "tcp" {
+		transport = &gelfTCP{addr: addr}
+	} else {
+		writer, err := gelf.NewWriter(addr)
+		if err != nil {
+			return nil, nil, err
+		}
+		writer.Facility = gelfFacility
+		transport = writer
+	}
+
+	host, err := os.Hostname()
+	if err != nil {
+		return nil, nil, err
+	}
+	sender := newGELFSender(addr, transport)
+	return &gelfHandler{sender: sender, level: level, host: host, fields: map[string]any{}}, sender, nil
+}
+
+func (h *gelfHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *gelfHandler) Handle(_ context.Context, record slog.Record) error {
+	fields := maps.Clone(h.fields)
+	record.Attrs(func(attr slog.Attr) bool {
+		addField(fields, h.prefix, attr)
+		return true
+	})
+
+	h.sender.send(&gelf.Message{
+		Version:  "1.1",
+		Host:     h.host,
+		Short:    record.Message,
+		TimeUnix: float64(record.Time.UnixNano()) / float64(time.Second),
+		Level:    syslogLevel(record.Level),
+		Facility: gelfFacility,
+		Extra:    fields,
+	})
+	return nil
+}
+
+func (h *gelfHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handler := *h
+	handler.fields = maps.Clone(h.fields)
+	for _, attr := range attrs {
+		addField(handler.fields, h.prefix, attr)
+	}
+	return &handler
+}
+
+func (h *gelfHandler) WithGroup(name string) slog.Handler {
+	handler := *h
+	handler.prefix = h.prefix + name + "_"
+	return &handler
+}
+
+func addField(fields map[string]any, prefix string, attr slog.Attr) {
+	attr.Value = attr.Value.Resolve()
+	switch attr.Value.Kind() {
+	case slog.KindGroup:
+		groupPrefix := prefix
+		if attr.Key != "" {
+			groupPrefix += attr.Key + "_"
+		}
+		for _, groupAttr := range attr.Value.Group() {
+			addField(fields, groupPrefix, groupAttr)
+		}
+	case slog.KindTime, slog.KindDuration:
+		fields["_"+prefix+attr.Key] = attr.Value.String()
+	case slog.KindAny:
+		if err, ok := attr.Value.Any().(error); ok {
+			fields["_"+prefix+attr.Key] = err.Error()
+		} else {
+			fields["_"+prefix+attr.Key] = attr.Value.Any()
+		}
+	default:
+		fields["_"+prefix+attr.Key] = attr.Value.Any()
+	}
+}
+
+func syslogLevel(level slog.Level) int32 {
+	switch {
+	case level >= slog.LevelError:
+		return gelf.LOG_ERR
+	case level >= slog.LevelWarn:
+		return gelf.LOG_WARNING
+	case level >= slog.LevelInfo:
+		return gelf.LOG_INFO
+	default:
+		return gelf.LOG_DEBUG
+	}
+}
+
+type gelfTransport interface {
+	WriteMessage(m *gelf.Message) error
+	Close() error
+}
+
+// gelfTCP sends null byte terminated messages and dials again when the
+// connection breaks, so Graylog can be started after the server.
+type gelfTCP struct {
+	addr string
+	conn net.Conn
+}
+
+func (t *gelfTCP) WriteMessage(m *gelf.Message) error {
+	var buf bytes.Buffer
+	if err := m.MarshalJSONBuf(&buf); err != nil {
+		return err
+	}
+	buf.WriteByte(0)
+
+	for attempt := 0; attempt < 2; attempt++ {
+		if t.conn == nil {
+			conn, err := net.DialTimeout("tcp", t.addr, gelfDialTimeout)
+			if err != nil {
+				return err
+			}
+			t.conn = conn
+		}
+		t.conn.SetWriteDeadline(time.Now().Add(gelfWriteTimeout))
+		if _, err := t.conn.Write(buf.Bytes()); err == nil {
+			return nil
+		}
+		t.conn.Close()
+		t.conn = nil
+	}
+	return fmt.Errorf("could not write to %s", t.addr)
+}
+
+func (t *gelfTCP) Close() error {
+	if t.conn == nil {
+		return nil
+	}
+	return t.conn.Close()
+}
+
+// gelfSender queues messages so logging never waits for the network. When
+// the queue is full messages are dropped, and failures are reported on
+// stderr instead of vanishing. Only run uses the transport until it is done.
+type gelfSender struct {
+	addr      string
+	transport gelfTransport
+	queue     chan *gelf.Message
+	stop      chan struct{} // closed when Close gives up on the queue
+	done      chan struct{} // closed when run returned
+
+	mu        sync.Mutex
+	closed    bool
+	dropped   int
+	lastError time.Time
+}
+
+func newGELFSender(addr string, transport gelfTransport) *gelfSender {
+	s := &gelfSender{
+		addr:      addr,
+		transport: transport,
+		queue:     make(chan *gelf.Message, gelfQueueSize),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+func (s *gelfSender) send(m *gelf.Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return
+	}
+	select {
+	case s.queue <- m:
+	default:
+		s.report(fmt.Errorf("queue is full"))
+	}
+}
+
+func (s *gelfSender) run() {
+	defer close(s.done)
+	for {
+		// Stopping wins over the messages still queued
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+		select {
+		case <-s.stop:
+			return
+		case m, ok := <-s.queue:
+			if !ok {
+				return
+			}
+			if err := s.transport.WriteMessage(m); err != nil {
+				s.mu.Lock()
+				s.report(err)
+				s.mu.Unlock()
+			}
+		}
+	}
+}
+
+// report counts a dropped message, s.mu must be held.
+func (s *gelfSender) report(err error) {
+	s.dropped++
+	if time.Since(s.lastError) < gelfErrorInterval {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "gelf log sink %s: dropped %d messages: %v\n", s.addr, s.dropped, err)
+	s.dropped = 0
+	s.lastError = time.Now()
+}
+
+// Close sends what is still queued, giving up after a few seconds. The
+// transport is closed once run returned, the message being written when it
+// gave up still gets its write timeout.
+func (s *gelfSender) Close() error {
+	s.mu.Lock()
+	if s.closed {
+		s.mu.Unlock()
+		<-s.done
+		return nil
+	}
+	s.closed = true
+	close(s.queue)
+	s.mu.Unlock()
+
+	select {
+	case <-s.done:
+	case <-time.After(gelfCloseTimeout):
+		close(s.stop)
+		<-s.done
+	}
+	return s.transport.Close()
+}
@@ -0,0 +1,200 @@
+// Package logging builds the slog handler from the configured sinks. Every
+// sink has its own level and a record goes to each sink that accepts it.
+package logging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"slices"
+)
+
+const (
+	SinkStdout string = "stdout"
+	SinkFile   string = "file"
+	SinkGELF   string = "gelf"
+)
+
+const (
+	FormatText string = "text"
+	FormatJSON string = "json"
+)
+
+type Config struct {
+	Level string // used by the sinks that do not set their own
+	Sinks []SinkConfig
+}
+
+type SinkConfig struct {
+	Type   string // stdout, file or gelf
+	Level  string
+	Format string // text or json, for stdout and file
+
+	Path       string // file
+	MaxSizeMB  int    // file, rotated when it grows past this, 0 never rotates
+	MaxBackups int    // file, how many rotated files are kept
+
+	Addr     string // gelf, host:port of the Graylog input
+	Protocol string // gelf, udp or tcp
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Level: "info",
+		Sinks: []SinkConfig{{Type: SinkStdout, Format: FormatJSON}},
+	}
+}
+
+func (c Config) Validate() error {
+	errs := []error{}
+	if _, err := parseLevel(c.Level); err != nil {
+		errs = append(errs, err)
+	}
+	if len(c.Sinks) == 0 {
+		errs = append(errs, errors.New("at least one log sink is required"))
+	}
+	for i, sink := range c.Sinks {
+		if err := sink.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("sink %d: %w", i+1, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s SinkConfig) validate() error {
+	if s.Level != "" {
+		if _, err := parseLevel(s.Level); err != nil {
+			return err
+		}
+	}
+	switch s.Type {
+	case SinkStdout, SinkFile:
+		if s.Format != "" && s.Format != FormatText && s.Format != FormatJSON {
+			return errors.New("format must be text or json")
+		}
+		if s.Type == SinkFile && s.Path == "" {
+			return errors.New("file sinks need a path")
+		}
+		if s.MaxSizeMB < 0 || s.MaxBackups < 0 {
+			return errors.New("max size and backups cannot be negative")
+		}
+	case SinkGELF:
+		if s.Addr == "" {
+			return errors.New("gelf sinks need an addr")
+		}
+		if s.Protocol != "" && s.Protocol != "udp" && s.Protocol != "tcp" {
+			return errors.New("protocol must be udp or tcp")
+		}
+	default:
+		return fmt.Errorf("unknown sink type %q, use stdout, file or gelf", s.Type)
+	}
+	return nil
+}
+
+func parseLevel(level string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(level)); err != nil {
+		return l, fmt.Errorf("unknown log level %q, use debug, info, warn or error", level)
+	}
+	return l, nil
+}
+
+// New opens every sink. Close the returned closer on shutdown so files are
+// flushed and queued GELF messages are sent.
+func New(config Config) (slog.Handler, io.Closer, error) {
+	if err := config.Validate(); err != nil {
+		return nil, nil, err
+	}
+	defaultLevel, _ := parseLevel(config.Level)
+
+	handlers := []slog.Handler{}
+	closers := closers{}
+	for _, sink := range config.Sinks {
+		level := defaultLevel
+		if sink.Level != "" {
+			level, _ = parseLevel(sink.Level)
+		}
+		handler, closer, err := newSink(sink, level)
+		if err != nil {
+			closers.Close()
+			return nil, nil, fmt.Errorf("opening %s log sink: %w", sink.Type, err)
+		}
+		handlers = append(handlers, handler)
+		if closer != nil {
+			closers = append(closers, closer)
+		}
+	}
+	if len(handlers) == 1 {
+		return handlers[0], closers, nil
+	}
+	return fanout(handlers), closers, nil
+}
+
+func newSink(sink SinkConfig, level slog.Level) (slog.Handler, io.Closer, error) {
+	switch sink.Type {
+	case SinkFile:
+		file, err := openRotatingFile(sink.Path, int64(sink.MaxSizeMB)<<20, sink.MaxBackups)
+		if err != nil {
+			return nil, nil, err
+		}
+		return formatHandler(file, sink.Format, level), file, nil
+	case SinkGELF:
+		return newGELFHandler(sink.Addr, sink.Protocol, level)
+	default:
+		return formatHandler(os.Stdout, sink.Format, level), nil, nil
+	}
+}
+
+func formatHandler(w io.Writer, format string, level slog.Level) slog.Handler {
+	options := &slog.HandlerOptions{Level: level}
+	if format == FormatText {
+		return slog.NewTextHandler(w, options)
+	}
+	return slog.NewJSONHandler(w, options)
+}
+
+type closers []io.Closer
+
+func (c closers) Close() error {
+	errs := []error{}
+	for _, closer := range c {
+		errs = append(errs, closer.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// fanout sends each record to every handler that is enabled for its level.
+type fanout []slog.Handler
+
+func (f fanout) Enabled(ctx context.Context, level slog.Level) bool {
+	return slices.ContainsFunc(f, func(h slog.Handler) bool { return h.Enabled(ctx, level) })
+}
+
+func (f fanout) Handle(ctx context.Context, record slog.Record) error {
+	errs := []error{}
+	for _, h := range f {
+		if h.Enabled(ctx, record.Level) {
+			errs = append(errs, h.Handle(ctx, record.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (f fanout) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(fanout, len(f))
+	for i, h := range f {
+		handlers[i] = h.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+func (f fanout) WithGroup(name string) slog.Handler {
+	handlers := make(fanout, len(f))
+	for i, h := range f {
+		handlers[i] = h.WithGroup(name)
+	}
+	return handlers
+}
@@ -0,0 +1,279 @@
+package logging
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Graylog2/go-gelf/gelf"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr string
+	}{
+		{name: "defaults", config: DefaultConfig()},
+		{name: "unknown level", config: Config{Level: "loud", Sinks: DefaultConfig().Sinks}, wantErr: "unknown log level"},
+		{name: "no sinks", config: Config{Level: "info"}, wantErr: "at least one log sink"},
+		{name: "unknown sink", config: Config{Level: "info", Sinks: []SinkConfig{{Type: "syslog"}}}, wantErr: "unknown sink type"},
+		{name: "file without path", config: Config{Level: "info", Sinks: []SinkConfig{{Type: SinkFile}}}, wantErr: "need a path"},
+		{name: "bad format", config: Config{Level: "info", Sinks: []SinkConfig{{Type: SinkStdout, Format: "xml"}}}, wantErr: "text or json"},
+		{name: "gelf without addr", config: Config{Level: "info", Sinks: []SinkConfig{{Type: SinkGELF}}}, wantErr: "need an addr"},
+		{name: "gelf over http", config: Config{Level: "info", Sinks: []SinkConfig{{Type: SinkGELF, Addr: "localhost:12201", Protocol: "http"}}}, wantErr: "udp or tcp"},
+		{name: "bad sink level", config: Config{Level: "info", Sinks: []SinkConfig{{Type: SinkStdout, Level: "loud"}}}, wantErr: "unknown log level"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("Validate() = %v, want an error mentioning %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// TestGELFOverUDP stands in for Graylog with a local UDP listener.
+func TestGELFOverUDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	handler, closer, err := New(Config{Level: "info", Sinks: []SinkConfig{{Type: SinkGELF, Addr: conn.LocalAddr().String()}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(handler)
+	logger.Debug("below the level")
+	logger.WithGroup("game").Info("Created game", "id", "g1", "players", 3)
+	if err := closer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 65536)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := decodeGELF(t, buf[:n])
+	if message["short_message"] != "Created game" {
+		t.Errorf("short_message = %v, want the first record above the level", message["short_message"])
+	}
+	if message["_game_id"] != "g1" || message["_game_players"] != float64(3) {
+		t.Errorf("fields = %v, want the group joined into _game_id and _game_players", message)
+	}
+	if message["level"] != float64(6) {
+		t.Errorf("level = %v, want 6 for info", message["level"])
+	}
+}
+
+// decodeGELF unpacks a UDP datagram, which the GELF writer compresses.
+func decodeGELF(t *testing.T, datagram []byte) map[string]any {
+	t.Helper()
+	var r io.Reader = bytes.NewReader(datagram)
+	var err error
+	switch {
+	case bytes.HasPrefix(datagram, []byte{0x1f, 0x8b}):
+		r, err = gzip.NewReader(r)
+	case datagram[0] == 0x78:
+		r, err = zlib.NewReader(r)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	message := map[string]any{}
+	if err := json.NewDecoder(r).Decode(&message); err != nil {
+		t.Fatal(err)
+	}
+	return message
+}
+
+func TestGELFOverTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		line, _ := bufio.NewReader(conn).ReadString(0)
+		received <- strings.TrimSuffix(line, "\x00")
+	}()
+
+	handler, closer, err := New(Config{Level: "info", Sinks: []SinkConfig{{Type: SinkGELF, Addr: listener.Addr().String(), Protocol: "tcp"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	slog.New(handler).Warn("Player is away", "error", io.EOF)
+	closer.Close()
+
+	select {
+	case line := <-received:
+		message := map[string]any{}
+		if err := json.Unmarshal([]byte(line), &message); err != nil {
+			t.Fatalf("message %q is not JSON: %v", line, err)
+		}
+		if message["short_message"] != "Player is away" || message["_error"] != "EOF" {
+			t.Errorf("message = %v, want the record with the error as a string", message)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("no message received")
+	}
+}
+
+// slowTransport takes its time with every message, like Graylog under load.
+type slowTransport struct {
+	writing atomic.Bool
+	written atomic.Int32
+	closed  atomic.Bool
+	raced   atomic.Bool // closed while a message was being written
+}
+
+func (s *slowTransport) WriteMessage(m *gelf.Message) error {
+	s.writing.Store(true)
+	time.Sleep(20 * time.Millisecond)
+	if s.closed.Load() {
+		s.raced.Store(true)
+	}
+	s.written.Add(1)
+	s.writing.Store(false)
+	return nil
+}
+
+func (s *slowTransport) Close() error {
+	if s.writing.Load() {
+		s.raced.Store(true)
+	}
+	s.closed.Store(true)
+	return nil
+}
+
+// TestGELFCloseWaitsForTheSender gives up on a long queue, the transport is
+// only closed after the sender stopped writing.
+func TestGELFCloseWaitsForTheSender(t *testing.T) {
+	saved := gelfCloseTimeout
+	gelfCloseTimeout = 100 * time.Millisecond
+	defer func() { gelfCloseTimeout = saved }()
+
+	transport := &slowTransport{}
+	sender := newGELFSender("slow", transport)
+	for i := 0; i < 50; i++ {
+		sender.send(&gelf.Message{Short: "line"})
+	}
+	sender.Close()
+	sender.Close()
+
+	if transport.raced.Load() {
+		t.Errorf("the transport was closed while a message was being written")
+	}
+	if !transport.closed.Load() {
+		t.Errorf("the transport was not closed")
+	}
+	if n := transport.written.Load(); n == 0 || n == 50 {
+		t.Errorf("%d of 50 messages written, want Close to give up part way", n)
+	}
+	sender.send(&gelf.Message{Short: "after close"})
+}
+
+func TestSinkLevels(t *testing.T) {
+	dir := t.TempDir()
+	all := filepath.Join(dir, "all.log")
+	errorsOnly := filepath.Join(dir, "errors.log")
+	handler, closer, err := New(Config{Level: "debug", Sinks: []SinkConfig{
+		{Type: SinkFile, Path: all, Format: FormatText},
+		{Type: SinkFile, Path: errorsOnly, Level: "error"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger := slog.New(handler)
+	logger.Debug("debug record")
+	logger.Error("error record")
+	closer.Close()
+
+	tests := []struct {
+		path string
+		want []string
+		skip []string
+	}{
+		{path: all, want: []string{"debug record", "error record"}},
+		{path: errorsOnly, want: []string{`"msg":"error record"`}, skip: []string{"debug record"}},
+	}
+	for _, tt := range tests {
+		data, err := os.ReadFile(tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, want := range tt.want {
+			if !strings.Contains(string(data), want) {
+				t.Errorf("%s = %q, want %q in it", filepath.Base(tt.path), data, want)
+			}
+		}
+		for _, skip := range tt.skip {
+			if strings.Contains(string(data), skip) {
+				t.Errorf("%s = %q, want no %q in it", filepath.Base(tt.path), data, skip)
+			}
+		}
+	}
+}
+
+func TestRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "party.log")
+	file, err := openRotatingFile(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		if _, err := file.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	file.Close()
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: path, want: "fourth\n"},
+		{path: path + ".1", want: "third\n"},
+		{path: path + ".2", want: "second\n"},
+	}
+	for _, tt := range tests {
+		data, err := os.ReadFile(tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != tt.want {
+			t.Errorf("%s = %q, want %q", filepath.Base(tt.path), data, tt.want)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("found a third backup, want at most 2")
+	}
+}
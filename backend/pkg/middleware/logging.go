@@ -0,0 +1,180 @@
+// Package middleware holds the http.Handler wrappers the server installs
+// around the handlers.
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const requestIdHeader string = "X-Request-Id"
+
+// Form bodies bigger than this are not logged, the handler still gets all of it.
+const maxLoggedForm int64 = 64 << 10
+
+type LogConfig struct {
+	// Redact lists the query and form fields whose values are never logged.
+	Redact []string
+	// LogForm logs the fields of url encoded request bodies at debug level.
+	// The body is buffered and handed to the handler untouched.
+	LogForm bool
+	// Correlate returns attributes that tie a request to the game state, e.g.
+	// the game and player ids. They are added to the request context and to
+	// the access log.
+	Correlate func(r *http.Request) []slog.Attr
+}
+
+func DefaultLogConfig() LogConfig {
+	return LogConfig{
+		Redact:  []string{"game-password", "password", "player-answer", "text"},
+		LogForm: false,
+	}
+}
+
+type contextKey struct{}
+
+// requestInfo is what the ContextHandler adds to every record logged with
+// the request context.
+type requestInfo struct {
+	id    string
+	attrs []slog.Attr
+}
+
+// RequestId returns the id the Logging middleware gave the request.
+func RequestId(ctx context.Context) string {
+	if info, ok := ctx.Value(contextKey{}).(*requestInfo); ok {
+		return info.id
+	}
+	return ""
+}
+
+// Logging gives every request an id, which is also sent back in the
+// X-Request-Id header, and logs method, path, status, latency and size when
+// the request is done.
+func Logging(config LogConfig) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			id := r.Header.Get(requestIdHeader)
+			if id == "" || len(id) > 64 {
+				id = uuid.NewString()
+			}
+			info := &requestInfo{id: id}
+			if config.Correlate != nil {
+				info.attrs = config.Correlate(r)
+			}
+			r = r.WithContext(context.WithValue(r.Context(), contextKey{}, info))
+			w.Header().Set(requestIdHeader, id)
+
+			if config.LogForm {
+				logForm(r, config.Redact)
+			}
+
+			rw := &responseWriter{ResponseWriter: w}
+			next.ServeHTTP(rw, r)
+
+			attrs := []slog.Attr{
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", rw.statusCode()),
+				slog.Duration("latency", time.Since(start)),
+				slog.Int64("bytes", rw.bytes),
+				slog.String("remote_addr", r.RemoteAddr),
+			}
+			if r.URL.RawQuery != "" {
+				attrs = append(attrs, slog.String("query", redact(r.URL.Query(), config.Redact).Encode()))
+			}
+			// Path values are only known after routing, so correlate again
+			if config.Correlate != nil {
+				info.attrs = config.Correlate(r)
+			}
+			slog.LogAttrs(r.Context(), slog.LevelInfo, "Handled request", attrs...)
+		})
+	}
+}
+
+func logForm(r *http.Request, redactFields []string) {
+	if r.Body == nil || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/x-www-form-urlencoded") {
+		return
+	}
+	if r.ContentLength < 0 || r.ContentLength > maxLoggedForm {
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), r.Body))
+	if err != nil {
+		return
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return
+	}
+	slog.DebugContext(r.Context(), "Request form", "form", redact(form, redactFields).Encode())
+}
+
+func redact(values url.Values, fields []string) url.Values {
+	redacted := url.Values{}
+	for key, value := range values {
+		if slices.Contains(fields, key) {
+			redacted[key] = []string{"REDACTED"}
+		} else {
+			redacted[key] = value
+		}
+	}
+	return redacted
+}
+
+// responseWriter records the status and size of the response. It keeps
+// flushing working for the event streams and exposes the wrapped writer to
+// http.ResponseController.
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *responseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		flusher.Flush()
+	}
+}
+
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *responseWriter) statusCode() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	requestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name: "partygame_http_request_duration_seconds",
+		Help: "How long the handlers take to respond, long polls and event streams included.",
+		// Long polls wait up to a minute, so the buckets go well past the defaults
+		Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10, 30, 60, 120},
+	}, []string{"handler", "method"})
+	requestErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "partygame_http_errors_total",
+		Help: "Responses with a 4xx or 5xx status.",
+	}, []string{"handler", "code"})
+)
+
+// Metrics records the latency and the error responses of every request.
+// route names the handler, it should return the matched route pattern so
+// that ids in the path do not end up in the labels.
+func Metrics(route func(*http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rw := &responseWriter{ResponseWriter: w}
+			next.ServeHTTP(rw, r)
+
+			handler := route(r)
+			if handler == "" {
+				handler = "unmatched"
+			}
+			requestDuration.WithLabelValues(handler, r.Method).Observe(time.Since(start).Seconds())
+			if status := rw.statusCode(); status >= 400 {
+				requestErrors.WithLabelValues(handler, strconv.Itoa(status)).Inc()
+			}
+		})
+	}
+}
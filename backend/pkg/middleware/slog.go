@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"context"
+	"log/slog"
+)
+
+// ContextHandler adds the request id and the correlation attributes to every
+// record logged with a request context, e.g. slog.InfoContext(r.Context(), ...).
+type ContextHandler struct {
+	slog.Handler
+}
+
+func NewContextHandler(handler slog.Handler) *ContextHandler {
+	return &ContextHandler{handler}
+}
+
+func (h *ContextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if info, ok := ctx.Value(contextKey{}).(*requestInfo); ok {
+		record.AddAttrs(slog.String("request_id", info.id))
+		record.AddAttrs(info.attrs...)
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *ContextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &ContextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h *ContextHandler) WithGroup(name string) slog.Handler {
+	return &ContextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,152 @@
+// Package ratelimit keeps token buckets and lockouts per key, e.g. per client
+// IP or per player, to slow down scripts hammering the server.
+package ratelimit
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Keys that were not seen for this long are forgotten, their buckets are
+// full again by then anyway.
+const idleTimeout time.Duration = 30 * time.Minute
+
+type Rate struct {
+	PerMinute float64 // how many requests are allowed on average, 0 disables the limit
+	Burst     int     // how many requests are allowed at once
+}
+
+type entry struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// Limiter is a token bucket per key.
+type Limiter struct {
+	rate      Rate
+	lock      sync.Mutex
+	entries   map[string]*entry
+	lastPrune time.Time
+}
+
+func NewLimiter(r Rate) *Limiter {
+	return &Limiter{rate: r, entries: map[string]*entry{}, lastPrune: time.Now()}
+}
+
+// Allow takes a token from the bucket of key. When it is empty it returns
+// false and how long until the next token.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	if l.rate.PerMinute <= 0 {
+		return true, 0
+	}
+	now := time.Now()
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.prune(now)
+
+	e, ok := l.entries[key]
+	if !ok {
+		e = &entry{limiter: rate.NewLimiter(rate.Limit(l.rate.PerMinute/60), max(l.rate.Burst, 1))}
+		l.entries[key] = e
+	}
+	e.lastSeen = now
+	reservation := e.limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+func (l *Limiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < time.Minute {
+		return
+	}
+	l.lastPrune = now
+	for key, e := range l.entries {
+		if now.Sub(e.lastSeen) > idleTimeout {
+			delete(l.entries, key)
+		}
+	}
+}
+
+type failures struct {
+	count       int
+	first       time.Time
+	lockedUntil time.Time
+}
+
+// Lockout locks a key out after too many failures within the lockout
+// duration, e.g. wrong passwords from one IP.
+type Lockout struct {
+	attempts int
+	duration time.Duration
+	lock     sync.Mutex
+	keys     map[string]*failures
+}
+
+// NewLockout locks a key for duration after attempts failures that came
+// within duration of each other. Zero attempts disables it.
+func NewLockout(attempts int, duration time.Duration) *Lockout {
+	return &Lockout{attempts: attempts, duration: duration, keys: map[string]*failures{}}
+}
+
+// Locked reports whether key is locked out and for how much longer.
+func (l *Lockout) Locked(key string) (bool, time.Duration) {
+	if l.attempts <= 0 {
+		return false, 0
+	}
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	f, ok := l.keys[key]
+	if !ok {
+		return false, 0
+	}
+	if wait := time.Until(f.lockedUntil); wait > 0 {
+		return true, wait
+	}
+	return false, 0
+}
+
+// Fail records a failure of key and reports whether it is locked out now.
+func (l *Lockout) Fail(key string) bool {
+	if l.attempts <= 0 {
+		return false
+	}
+	now := time.Now()
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for k, f := range l.keys {
+		if now.Sub(f.first) > l.duration && now.After(f.lockedUntil) {
+			delete(l.keys, k)
+		}
+	}
+
+	f, ok := l.keys[key]
+	if !ok {
+		f = &failures{first: now}
+		l.keys[key] = f
+	}
+	f.count++
+	if f.count >= l.attempts {
+		f.lockedUntil = now.Add(l.duration)
+		f.count = 0
+		f.first = now
+		return true
+	}
+	return false
+}
+
+// ClientIP is the address the request came from. Forwarded headers are
+// ignored, anybody can set them.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
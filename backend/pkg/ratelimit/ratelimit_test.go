@@ -0,0 +1,107 @@
+package ratelimit
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	tests := []struct {
+		name    string
+		rate    Rate
+		allowed int // requests of one key in a row that pass
+	}{
+		{name: "burst passes at once", rate: Rate{PerMinute: 60, Burst: 3}, allowed: 3},
+		{name: "no burst is one request", rate: Rate{PerMinute: 60, Burst: 0}, allowed: 1},
+		{name: "disabled", rate: Rate{PerMinute: 0, Burst: 1}, allowed: 10},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLimiter(tt.rate)
+			for i := 0; i < tt.allowed; i++ {
+				if ok, _ := l.Allow("ann"); !ok {
+					t.Fatalf("request %d was limited, want %d to pass", i+1, tt.allowed)
+				}
+			}
+			if tt.rate.PerMinute <= 0 {
+				return
+			}
+			ok, wait := l.Allow("ann")
+			if ok {
+				t.Fatalf("request %d passed, want it limited", tt.allowed+1)
+			}
+			if wait <= 0 || wait > time.Minute/time.Duration(tt.rate.PerMinute) {
+				t.Errorf("wait = %s, want up to the time of one token", wait)
+			}
+			if ok, _ := l.Allow("bob"); !ok {
+				t.Errorf("another key was limited, want its own bucket")
+			}
+		})
+	}
+}
+
+func TestLockout(t *testing.T) {
+	tests := []struct {
+		name     string
+		attempts int
+		failures int
+		locked   bool
+	}{
+		{name: "below the attempts", attempts: 3, failures: 2, locked: false},
+		{name: "at the attempts", attempts: 3, failures: 3, locked: true},
+		{name: "disabled", attempts: 0, failures: 10, locked: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := NewLockout(tt.attempts, time.Minute)
+			lockedByFail := false
+			for i := 0; i < tt.failures; i++ {
+				lockedByFail = l.Fail("ann")
+			}
+			if lockedByFail != tt.locked {
+				t.Errorf("Fail() = %v, want %v", lockedByFail, tt.locked)
+			}
+			locked, wait := l.Locked("ann")
+			if locked != tt.locked {
+				t.Errorf("Locked() = %v, want %v", locked, tt.locked)
+			}
+			if locked && (wait <= 0 || wait > time.Minute) {
+				t.Errorf("wait = %s, want up to the lockout duration", wait)
+			}
+			if locked, _ := l.Locked("bob"); locked {
+				t.Errorf("another key is locked out")
+			}
+		})
+	}
+}
+
+func TestLockoutExpires(t *testing.T) {
+	l := NewLockout(1, 20*time.Millisecond)
+	if !l.Fail("ann") {
+		t.Fatal("Fail() = false, want a lockout after the only attempt")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if locked, _ := l.Locked("ann"); locked {
+		t.Errorf("still locked out after the lockout duration")
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		remoteAddr string
+		want       string
+	}{
+		{remoteAddr: "192.168.1.20:51234", want: "192.168.1.20"},
+		{remoteAddr: "[fe80::1]:51234", want: "fe80::1"},
+		{remoteAddr: "no port", want: "no port"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = tt.remoteAddr
+		r.Header.Set("X-Forwarded-For", "10.0.0.1")
+		if got := ClientIP(r); got != tt.want {
+			t.Errorf("ClientIP(%q) = %q, want %q", tt.remoteAddr, got, tt.want)
+		}
+	}
+}
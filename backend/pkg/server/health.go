@@ -0,0 +1,65 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+type readiness struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+// healthz tells that the process is up and serving, nothing more.
+func (s *Server) healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("ok\n"))
+}
+
+// readyz tells whether the server should get traffic: the game store can be
+// saved, the templates render and the server is not shutting down.
+func (s *Server) readyz(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]error{
+		"store":     s.checkStore(),
+		"templates": s.handlers.Ready(),
+	}
+	if s.draining.Load() {
+		checks["shutdown"] = errors.New("server is shutting down")
+	}
+
+	response := readiness{Status: "ready", Checks: map[string]string{}}
+	status := http.StatusOK
+	for name, err := range checks {
+		response.Checks[name] = "ok"
+		if err != nil {
+			response.Checks[name] = err.Error()
+			response.Status = "not ready"
+			status = http.StatusServiceUnavailable
+		}
+	}
+	if status != http.StatusOK {
+		slog.WarnContext(r.Context(), "Server is not ready", "checks", response.Checks)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
+
+// checkStore makes sure the state can still be saved on shutdown, by creating
+// a file next to the state file.
+func (s *Server) checkStore() error {
+	if s.config.StateFile == "" {
+		return nil
+	}
+	probe, err := os.CreateTemp(filepath.Dir(s.config.StateFile), filepath.Base(s.config.StateFile)+".probe")
+	if err != nil {
+		return err
+	}
+	probe.Close()
+	return os.Remove(probe.Name())
+}
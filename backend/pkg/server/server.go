@@ -0,0 +1,229 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"party-game/pkg/gamelogic"
+	"party-game/pkg/handlers"
+	"party-game/pkg/lan"
+	"party-game/pkg/middleware"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+type Config struct {
+	Addr         string
+	TLSCertFile  string // TLS is enabled when both the cert and key are set
+	TLSKeyFile   string
+	TemplatesDir string // read templates from here and reload them on every request, empty uses the embedded ones
+	StateFile    string // where games are saved on shutdown and loaded on start, empty disables it
+
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	// WriteTimeout applies to the whole response, so it has to be longer than
+	// the long polls. Zero disables it, which the event streams need.
+	WriteTimeout    time.Duration
+	IdleTimeout     time.Duration
+	ShutdownTimeout time.Duration // how long in-flight requests get to finish on shutdown
+	LongPollTimeout time.Duration // how long the page handlers wait for the other players
+
+	// AdminToken enables the /debug endpoints, requests have to send it as a
+	// bearer token. Pprof additionally serves the profiler there.
+	AdminToken string
+	Pprof      bool
+
+	Limits handlers.LimitConfig
+
+	// Game holds the rules of new games, the janitor and the presence checks.
+	// The server makes up a room secret when none is configured.
+	Game gamelogic.Config
+
+	// LAN host mode binds to the LAN address instead of the host in Addr,
+	// advertises the server with mDNS and prints the join URL.
+	LAN lan.Config
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Addr:              ":8888",
+		TemplatesDir:      "",
+		StateFile:         "",
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		WriteTimeout:      0,
+		IdleTimeout:       120 * time.Second,
+		ShutdownTimeout:   15 * time.Second,
+		LongPollTimeout:   60 * time.Second,
+		Limits:            handlers.DefaultLimitConfig(),
+		Game:              gamelogic.DefaultConfig(),
+		LAN:               lan.DefaultConfig(),
+	}
+}
+
+type Server struct {
+	config     Config
+	handlers   *handlers.Handlers
+	handler    http.Handler
+	httpServer *http.Server
+	drain      context.CancelFunc
+	draining   atomic.Bool
+	lanHost    *lan.Host
+}
+
+// New builds the server with every handler registered. Nothing is listening
+// until Run is called.
+func New(config Config) (*Server, error) {
+	game := config.Game
+	if game.RoomSecret == "" {
+		game.RoomSecret = gamelogic.NewRoomSecret()
+	}
+	mux := http.NewServeMux()
+	h, err := handlers.AddHandlers(mux, handlers.Config{
+		TemplatesDir:    config.TemplatesDir,
+		LongPollTimeout: config.LongPollTimeout,
+		AdminToken:      config.AdminToken,
+		Pprof:           config.Pprof,
+		Limits:          config.Limits,
+		Game:            game,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	host, port, err := net.SplitHostPort(config.Addr)
+	if err != nil {
+		return nil, err
+	}
+	var lanHost *lan.Host
+	if config.LAN.Enabled {
+		if lanHost, err = lan.Find(config.LAN); err != nil {
+			return nil, err
+		}
+		host = lanHost.IP.String()
+	}
+
+	// Requests get a context that is cancelled when draining starts, so long
+	// polls and event streams return instead of holding up the shutdown.
+	drainCtx, drain := context.WithCancel(context.Background())
+	s := &Server{config: config, handlers: h, handler: mux, drain: drain, lanHost: lanHost}
+	mux.Handle("GET /metrics", promhttp.Handler())
+	mux.HandleFunc("GET /healthz", s.healthz)
+	mux.HandleFunc("GET /readyz", s.readyz)
+	s.Use(middleware.Metrics(func(r *http.Request) string {
+		_, pattern := mux.Handler(r)
+		return pattern
+	}))
+	s.httpServer = &http.Server{
+		Addr:              net.JoinHostPort(host, port),
+		ReadHeaderTimeout: config.ReadHeaderTimeout,
+		ReadTimeout:       config.ReadTimeout,
+		WriteTimeout:      config.WriteTimeout,
+		IdleTimeout:       config.IdleTimeout,
+		BaseContext:       func(net.Listener) context.Context { return drainCtx },
+	}
+	return s, nil
+}
+
+// Use wraps the current handler, the last middleware added runs first.
+func (s *Server) Use(middleware func(http.Handler) http.Handler) {
+	s.handler = middleware(s.handler)
+}
+
+// Run loads the saved state, serves until ctx is cancelled and then shuts
+// down gracefully, saving the state before returning.
+func (s *Server) Run(ctx context.Context) error {
+	if s.config.StateFile != "" {
+		if err := gamelogic.LoadState(s.config.StateFile, s.config.Game.RoomSecret); err != nil {
+			return err
+		}
+	}
+
+	s.httpServer.Handler = s.handler
+	listener, err := net.Listen("tcp", s.httpServer.Addr)
+	if err != nil {
+		return err
+	}
+
+	// The janitor and the presence checks change the games, so they have to
+	// stop before the state is saved
+	backgroundCtx, cancelBackground := context.WithCancel(context.Background())
+	background := sync.WaitGroup{}
+	janitor := func(ctx context.Context) { gamelogic.RunJanitor(ctx, s.config.Game.Expiry) }
+	presence := func(ctx context.Context) { gamelogic.RunPresence(ctx, s.config.Game.Players.AwayAfter) }
+	for _, run := range []func(context.Context){janitor, presence} {
+		background.Add(1)
+		go func() {
+			defer background.Done()
+			run(backgroundCtx)
+		}()
+	}
+	stopBackground := func() {
+		cancelBackground()
+		background.Wait()
+	}
+	defer stopBackground()
+	useTLS := s.config.TLSCertFile != "" && s.config.TLSKeyFile != ""
+	serveErr := make(chan error, 1)
+	go func() {
+		if useTLS {
+			slog.Info("Server is starting with TLS", "addr", s.httpServer.Addr)
+			serveErr <- s.httpServer.ServeTLS(listener, s.config.TLSCertFile, s.config.TLSKeyFile)
+		} else {
+			slog.Info("Server is starting", "addr", s.httpServer.Addr)
+			serveErr <- s.httpServer.Serve(listener)
+		}
+	}()
+
+	if s.lanHost != nil {
+		port := listener.Addr().(*net.TCPAddr).Port
+		if err := s.lanHost.Advertise(port); err != nil {
+			slog.Error("Could not advertise with mDNS, players have to type the address", "error", err)
+		}
+		defer s.lanHost.Shutdown()
+		scheme := "http"
+		if useTLS {
+			scheme = "https"
+		}
+		s.lanHost.PrintJoinInfo(os.Stdout, scheme, port)
+	}
+
+	select {
+	case err := <-serveErr:
+		return err
+	case <-ctx.Done():
+	}
+	stopBackground()
+	return s.Shutdown()
+}
+
+// Shutdown stops accepting connections, lets the long polls and event streams
+// return, waits for in-flight requests up to ShutdownTimeout and saves the state.
+func (s *Server) Shutdown() error {
+	slog.Info("Server is shutting down", "timeout", s.config.ShutdownTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), s.config.ShutdownTimeout)
+	defer cancel()
+
+	s.draining.Store(true)
+	s.drain()
+	err := s.httpServer.Shutdown(ctx)
+	if errors.Is(err, context.DeadlineExceeded) {
+		slog.Warn("Requests still running after the shutdown timeout, closing them")
+		err = s.httpServer.Close()
+	}
+
+	if s.config.StateFile != "" {
+		if saveErr := gamelogic.SaveState(s.config.StateFile); saveErr != nil {
+			slog.Error("Could not save state", "error", saveErr)
+			return errors.Join(err, saveErr)
+		}
+	}
+	slog.Info("Server stopped")
+	return err
+}
@@ -0,0 +1,55 @@
+//go:build ignore
+
+// fetch downloads the libraries that are not vendored yet into assets and
+// refuses to keep a file whose hash does not match its integrity.
+package main
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"party-game/static"
+	"path/filepath"
+)
+
+func main() {
+	for _, lib := range static.Libraries {
+		path := filepath.Join("assets", lib.File)
+		if _, err := os.Stat(path); err == nil {
+			continue
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			log.Fatal(err)
+		}
+		if err := fetch(lib, path); err != nil {
+			log.Fatalf("fetching %s: %v", lib.Source, err)
+		}
+		log.Printf("fetched %s", lib.File)
+	}
+}
+
+func fetch(lib static.Library, path string) error {
+	resp, err := http.Get(lib.Source)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.New(resp.Status)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	sum := sha512.Sum384(data)
+	if integrity := "sha384-" + base64.StdEncoding.EncodeToString(sum[:]); integrity != lib.Integrity {
+		return fmt.Errorf("integrity %s does not match %s", integrity, lib.Integrity)
+	}
+	return os.WriteFile(path, data, 0o644)
+}
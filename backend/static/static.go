@@ -0,0 +1,46 @@
+// Package static embeds the scripts, styles and images the pages load, so a
+// party on a local network without internet access still works.
+package static
+
+import (
+	"embed"
+	"io/fs"
+)
+
+//go:generate go run fetch.go
+
+//go:embed assets
+var assets embed.FS
+
+// FS holds the files served under /static/.
+var FS fs.FS
+
+func init() {
+	var err error
+	if FS, err = fs.Sub(assets, "assets"); err != nil {
+		panic(err)
+	}
+}
+
+// Library is a third party file that is vendored into assets. Libraries are
+// versioned in the file name and fetched from Source with go generate, which
+// checks the download against Integrity. The pages only ever load the
+// vendored file, the server does not start without it.
+type Library struct {
+	File      string
+	Source    string
+	Integrity string
+}
+
+var Libraries = []Library{
+	{
+		File:      "htmx-2.0.2.min.js",
+		Source:    "https://unpkg.com/htmx.org@2.0.2/dist/htmx.min.js",
+		Integrity: "sha384-Y7hw+L/jvKeWIRRkqWYfPcvVxHzVzn5REgzbawhxAuQGwX1XWe70vji+VSeHOThJ",
+	},
+	{
+		File:      "response-targets-2.0.3.js",
+		Source:    "https://unpkg.com/htmx-ext-response-targets@2.0.3/response-targets.js",
+		Integrity: "sha384-NtTh9TBZ2X/pFpfsVvQOjSsYWmjmqG6h5ioQWVAe2/j3AuTHRmfqvoqp+iOed+I0",
+	},
+}
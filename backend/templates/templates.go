@@ -0,0 +1,10 @@
+// Package templates embeds the HTML templates in the binary, so the server
+// does not depend on the directory it is started from.
+package templates
+
+import "embed"
+
+// FS holds layout.html, which every page is rendered inside, and the pages.
+//
+//go:embed *.html
+var FS embed.FS